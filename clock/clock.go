@@ -10,11 +10,11 @@ type FixedClock struct {
 	ts int64
 }
 
-func (c FixedClock) GetUnix() int64 {
+func (c *FixedClock) GetUnix() int64 {
 	return c.ts
 }
 
-func (c FixedClock) SetTs(ts int64) {
+func (c *FixedClock) SetTs(ts int64) {
 	c.ts = ts
 }
 
@@ -22,11 +22,11 @@ type OffsetClock struct {
 	offset int64
 }
 
-func (c OffsetClock) GetUnix() int64 {
+func (c *OffsetClock) GetUnix() int64 {
 	return time.Now().Unix() + c.offset
 }
 
-func (c OffsetClock) SetOffset(offset int64) {
+func (c *OffsetClock) SetOffset(offset int64) {
 	c.offset = offset
 }
 
@@ -1,6 +1,7 @@
 package dlock
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
@@ -17,8 +18,12 @@ var ErrorTimeout = errors.New("operation timeout")
 type DLock interface {
 	GetLock(key string, seconds int) (string, error)
 	GetLockWait(key string, seconds int, timeout time.Duration) (string, error)
+	// WaitLock 等待直到获得锁或ctx结束，参见WaitOptions
+	WaitLock(ctx context.Context, key string, seconds int, opts WaitOptions) (string, error)
 	DelLock(key string, secret string) error
 	SetExpiredTime(key, secret string, seconds int) error
+	// Acquire 获取自动续期的锁，参见Lock
+	Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error)
 }
 
 var logger *log.Logger
@@ -27,8 +32,9 @@ func init() {
 	logger = log.NewLoggerWithSentry("dlock")
 }
 
-func New(host, password string, dbNum int) DLock {
-	pool := rediscm.NewPool(host, password, dbNum)
+// New 生成DLock，opts用于设置连接池，参见redis.NewPool
+func New(host, password string, dbNum int, opts ...rediscm.PoolOption) DLock {
+	pool := rediscm.NewPool(host, password, dbNum, opts...)
 	return &dlock{pool}
 }
 
@@ -51,12 +57,6 @@ func randomValue() string {
 	return hex.EncodeToString(buf)
 }
 
-const script = `if redis.call("get",KEYS[1]) == ARGV[1] then
-return redis.call("del",KEYS[1])
-else
-return 0
-end`
-
 func (l *dlock) GetLock(key string, seconds int) (string, error) {
 	conn := l.pool.Get()
 	defer conn.Close()
@@ -70,78 +70,40 @@ func (l *dlock) GetLock(key string, seconds int) (string, error) {
 	return value, nil
 }
 
+// GetLockWait 等待直到获得锁，超时返回ErrorTimeout，参见WaitLock
 func (l *dlock) GetLockWait(key string, seconds int, timeout time.Duration) (string, error) {
-	conn := l.pool.Get()
-	defer conn.Close()
-
-	value := randomValue()
-
-	errChan := make(chan error, 1)
-
-	go func() {
-		for {
-			if _, err := redis.String(conn.Do("SET", key, value, "EX", seconds, "NX")); err != nil {
-				if err == redis.ErrNil {
-					time.Sleep(timeout / 10)
-					continue
-				} else {
-					errChan <- err
-					return
-				}
-			} else {
-				errChan <- nil
-				return
-			}
-		}
-	}()
-
-	select {
-	case <-time.After(timeout):
-		return "", ErrorTimeout
-	case err := <-errChan:
-		if err != nil {
-			return "", err
-		}
-		return value, nil
-	}
+	return getLockWait(l, key, seconds, timeout)
 }
 
-const setExpireScript = `if redis.call("get",KEYS[1]) == ARGV[1] then
-return redis.call("EXPIRE",KEYS[1], ARGV[2])
-else
-return 0
-end`
-
 func (l *dlock) SetExpiredTime(key string, secret string, seconds int) error {
-	// conn := l.pool.Get()
-	scr := redis.NewScript(1, setExpireScript)
 	conn := l.pool.Get()
 	defer conn.Close()
 
-	ret, err := redis.Int(scr.Do(conn, key, secret, seconds))
+	args := rediscm.NewScriptArgs(key).String(secret).Int(int64(seconds))
+	ret, err := redis.Int(rediscm.ExpireIfEqualScript.RunConn(context.Background(), conn, args))
 	if err != nil {
 		return err
 	}
 
 	if ret == 0 {
-		return errors.New("key does not exist or has expired")
+		return errNotOwner
 	}
 
 	return nil
 }
 
 func (l *dlock) DelLock(key string, secret string) error {
-	scr := redis.NewScript(1, script)
 	conn := l.pool.Get()
 	defer conn.Close()
 
-	ret, err := redis.Int(scr.Do(conn, key, secret))
+	args := rediscm.NewScriptArgs(key).String(secret)
+	ret, err := redis.Int(rediscm.DelIfEqualScript.RunConn(context.Background(), conn, args))
 	if err != nil {
 		return err
 	}
 
 	if ret == 0 {
-		return errors.New("key does not exist or has expired")
+		return errNotOwner
 	}
 
 	return nil
@@ -0,0 +1,78 @@
+package dlock
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	cmerrors "github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	rediscm "github.com/chenjie4255/tools/redis"
+)
+
+// ErrorStaleToken 写入携带的fencing token比已写入的旧，锁已过期并被其他人获得过
+// It is tagged with errcode.ResExpired.
+var ErrorStaleToken = cmerrors.NewWithTag("fencing token is stale", errcode.ResExpired)
+
+// FencingDLock 获取锁时同时返回按key单调递增的fencing token
+// The DLock returned by New implements it. Storage written under the lock should
+// reject stale tokens, see SetWithToken and mongohelper.UpdateOneWithToken.
+type FencingDLock interface {
+	DLock
+	GetLockWithToken(key string, seconds int) (secret string, token int64, err error)
+	AcquireWithToken(ctx context.Context, key string, ttl time.Duration) (*Lock, error)
+}
+
+// fencingKey keeps the last token of a lock, it never expires so tokens keep increasing.
+// key is used as its hash tag so that both keys are in one slot on a redis cluster,
+// keys which have a hash tag of their own are not supported there.
+func fencingKey(key string) string {
+	return "{" + key + "}.fencing_token"
+}
+
+// lockWithTokenScript locks KEYS[1] and increases the token in KEYS[2] at once
+// ARGV[1] secret, ARGV[2] ttl ms, returns the token or 0 if the lock is held
+var lockWithTokenScript = rediscm.RegisterScript("dlock.lock_with_token", 2, `if redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2], "NX") then
+return redis.call("INCR", KEYS[2])
+end
+return 0`)
+
+// GetLockWithToken 同GetLock，同时返回fencing token，锁被持有时返回ErrorLockHeld
+func (l *dlock) GetLockWithToken(key string, seconds int) (string, int64, error) {
+	secret := randomValue()
+	token, err := l.acquire(context.Background(), key, secret, time.Duration(seconds)*time.Second, true)
+	if err != nil {
+		return "", 0, err
+	}
+	return secret, token, nil
+}
+
+// setWithTokenScript sets KEYS[2] if ARGV[1] is not older than the token in KEYS[1]
+// ARGV[1] token, ARGV[2] value, ARGV[3] expires seconds, returns 1 or 0 if the token is stale
+var setWithTokenScript = rediscm.RegisterScript("dlock.set_with_token", 2, `local last = tonumber(redis.call("GET", KEYS[1]) or "0")
+if tonumber(ARGV[1]) < last then
+return 0
+end
+redis.call("SET", KEYS[1], ARGV[1])
+if tonumber(ARGV[3]) > 0 then
+redis.call("SET", KEYS[2], ARGV[2], "EX", ARGV[3])
+else
+redis.call("SET", KEYS[2], ARGV[2])
+end
+return 1`)
+
+// SetWithToken 类似CmpAndSet，token不比tokenKey中记录的旧时记录token并把key设为data，否则返回ErrorStaleToken
+// data is stored as is, marshal it with the codec of db to read it by db.Get.
+// expires <= 0 means no expiration.
+func SetWithToken(ctx context.Context, db rediscm.DB, tokenKey string, token int64, key string, data []byte, expires int) error {
+	args := rediscm.NewScriptArgs(tokenKey, key).Int(token).Bytes(data).Int(int64(expires))
+	ret, err := redis.Int(setWithTokenScript.Run(ctx, db, args))
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return ErrorStaleToken
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	rediscm "github.com/chenjie4255/tools/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFencingToken(t *testing.T) {
+	dl, db := getTestDLock(t)
+	fdl := dl.(FencingDLock)
+	ctx := context.Background()
+
+	secret, token1, err := fdl.GetLockWithToken("fencing_key", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), token1)
+	_, _, err = fdl.GetLockWithToken("fencing_key", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+	assert.NoError(t, fdl.DelLock("fencing_key", secret))
+
+	lock, err := fdl.AcquireWithToken(ctx, "fencing_key", time.Second)
+	assert.NoError(t, err)
+	token2 := lock.Token()
+	assert.Equal(t, int64(2), token2)
+	assert.NoError(t, lock.Unlock())
+
+	data, _ := rediscm.JSONCodec.Marshal("v2")
+	assert.NoError(t, SetWithToken(ctx, db, "fencing_res_token", token2, "fencing_res", data, 0))
+	// the holder of the newer token may write again
+	assert.NoError(t, SetWithToken(ctx, db, "fencing_res_token", token2, "fencing_res", data, 0))
+
+	// a paused holder of the old token is rejected
+	data, _ = rediscm.JSONCodec.Marshal("v1")
+	err = SetWithToken(ctx, db, "fencing_res_token", token1, "fencing_res", data, 0)
+	assert.Equal(t, ErrorStaleToken, err)
+	assert.True(t, errors.FindTag(err, errcode.ResExpired))
+
+	val := ""
+	assert.NoError(t, db.Get("fencing_res", &val))
+	assert.Equal(t, "v2", val)
+}
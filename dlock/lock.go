@@ -0,0 +1,244 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/chenjie4255/tools/log"
+	rediscm "github.com/chenjie4255/tools/redis"
+)
+
+var (
+	// ErrorLockHeld 锁已被其他人持有
+	ErrorLockHeld = errors.New("lock is held by another owner")
+	// ErrorLockLost 锁已过期或被其他人持有
+	ErrorLockLost = errors.New("lock ownership is lost")
+
+	errNotOwner = errors.New("key does not exist or has expired")
+)
+
+// renewDivisor 续期的间隔为ttl/renewDivisor
+const renewDivisor = 3
+
+// lockBackend stores the locks of Lock handles, ttl is in milliseconds precision
+type lockBackend interface {
+	// acquire returns ErrorLockHeld if the key is locked, the fencing token is 0 unless fencing
+	acquire(ctx context.Context, key, secret string, ttl time.Duration, fencing bool) (int64, error)
+	// renew returns errNotOwner if the lock is not held by secret
+	renew(ctx context.Context, key, secret string, ttl time.Duration) error
+	release(ctx context.Context, key, secret string) error
+}
+
+// Lock 通过Acquire获得的锁，在后台自动续期直到Unlock或丢失
+type Lock struct {
+	key     string
+	secret  string
+	token   int64
+	ttl     time.Duration
+	backend lockBackend
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	lost   chan struct{}
+	done   chan struct{}
+
+	mu       sync.Mutex
+	unlocked bool
+}
+
+func acquire(ctx context.Context, backend lockBackend, key string, ttl time.Duration, fencing bool) (*Lock, error) {
+	if ttl < renewDivisor*time.Millisecond {
+		return nil, errors.New("ttl is too short")
+	}
+
+	secret := randomValue()
+	start := time.Now()
+	token, err := backend.acquire(ctx, key, secret, ttl, fencing)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{
+		key:     key,
+		secret:  secret,
+		token:   token,
+		ttl:     ttl,
+		backend: backend,
+		lost:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	l.ctx, l.cancel = context.WithCancel(ctx)
+	go l.renewLoop(start)
+	return l, nil
+}
+
+// Key 锁的key
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Secret 锁的secret，可与DLock.DelLock等方法一起使用
+func (l *Lock) Secret() string {
+	return l.secret
+}
+
+// Token 锁的fencing token，只有AcquireWithToken获得的锁才有，否则为0
+func (l *Lock) Token() int64 {
+	return l.token
+}
+
+// Context 在锁丢失、Unlock或Acquire的ctx被取消时被取消
+func (l *Lock) Context() context.Context {
+	return l.ctx
+}
+
+// Lost 锁丢失时被关闭，Unlock不会关闭它
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// renewLoop renews the lock every ttl/renewDivisor. A renewal failing by a network error
+// is retried, but the lock is given up once it may expire before the next renewal.
+// renewedAt is taken before the request, the server side expiry can only start later.
+func (l *Lock) renewLoop(renewedAt time.Time) {
+	defer close(l.done)
+
+	interval := l.ttl / renewDivisor
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(l.ctx, interval)
+		err := l.backend.renew(ctx, l.key, l.secret, l.ttl)
+		cancel()
+		if err == nil {
+			renewedAt = start
+			continue
+		}
+		if l.ctx.Err() != nil {
+			return
+		}
+
+		logger.AddFile().WithFields(log.Fields{"key": l.key, "error": err.Error()}).Warn("failed to renew lock")
+		if err == errNotOwner || time.Since(renewedAt)+interval >= l.ttl {
+			close(l.lost)
+			l.cancel()
+			return
+		}
+	}
+}
+
+// Unlock 停止续期并释放锁，锁已丢失时返回ErrorLockLost
+func (l *Lock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.unlocked {
+		return nil
+	}
+	l.unlocked = true
+
+	l.cancel()
+	<-l.done
+
+	select {
+	case <-l.lost:
+		return ErrorLockLost
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), l.ttl)
+	defer cancel()
+	if err := l.backend.release(ctx, l.key, l.secret); err != nil {
+		if err == errNotOwner {
+			return ErrorLockLost
+		}
+		return err
+	}
+	return nil
+}
+
+// Acquire 获取key的锁并在后台每ttl/3续期一次，锁被持有时返回ErrorLockHeld
+// Cancelling ctx cancels the context of the Lock too, Unlock still has to be called
+// to release it.
+func (l *dlock) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return acquire(ctx, l, key, ttl, false)
+}
+
+// AcquireWithToken 同Acquire，Lock.Token()返回fencing token，参见GetLockWithToken
+func (l *dlock) AcquireWithToken(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return acquire(ctx, l, key, ttl, true)
+}
+
+func (l *dlock) acquire(ctx context.Context, key, secret string, ttl time.Duration, fencing bool) (int64, error) {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if fencing {
+		args := rediscm.NewScriptArgs(key, fencingKey(key)).String(secret).Millis(ttl)
+		token, err := redis.Int64(lockWithTokenScript.RunConn(ctx, conn, args))
+		if err != nil {
+			return 0, err
+		}
+		if token == 0 {
+			return 0, ErrorLockHeld
+		}
+		return token, nil
+	}
+
+	if _, err := redis.String(redis.DoContext(conn, ctx, "SET", key, secret, "PX", ttl.Milliseconds(), "NX")); err != nil {
+		if err == redis.ErrNil {
+			return 0, ErrorLockHeld
+		}
+		return 0, err
+	}
+	return 0, nil
+}
+
+func (l *dlock) renew(ctx context.Context, key, secret string, ttl time.Duration) error {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := rediscm.NewScriptArgs(key).String(secret).Millis(ttl)
+	ret, err := redis.Int(rediscm.PExpireIfEqualScript.RunConn(ctx, conn, args))
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return errNotOwner
+	}
+	return nil
+}
+
+func (l *dlock) release(ctx context.Context, key, secret string) error {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ret, err := redis.Int(rediscm.DelIfEqualScript.RunConn(ctx, conn, rediscm.NewScriptArgs(key).String(secret)))
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return errNotOwner
+	}
+	return nil
+}
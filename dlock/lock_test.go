@@ -0,0 +1,114 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rediscm "github.com/chenjie4255/tools/redis"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestDLock(t *testing.T) (DLock, rediscm.DB) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	rediscm.FlushDB(env.RedisHost, "", 3)
+	return New(env.RedisHost, "", 3), rediscm.NewDB(env.RedisHost, "", 3)
+}
+
+func TestAcquire(t *testing.T) {
+	dl, db := getTestDLock(t)
+	ctx := context.Background()
+
+	lock, err := dl.Acquire(ctx, "acquire_key", 300*time.Millisecond)
+	assert.NoError(t, err)
+	_, err = dl.Acquire(ctx, "acquire_key", 300*time.Millisecond)
+	assert.Equal(t, ErrorLockHeld, err)
+
+	// the lock is renewed past its ttl
+	time.Sleep(time.Second)
+	exists, _ := db.Exists("acquire_key")
+	assert.True(t, exists)
+	assert.NoError(t, lock.Context().Err())
+
+	assert.NoError(t, lock.Unlock())
+	assert.Error(t, lock.Context().Err())
+	exists, _ = db.Exists("acquire_key")
+	assert.False(t, exists)
+	assert.NoError(t, lock.Unlock())
+
+	// the old secret API works with the handle
+	lock, err = dl.Acquire(ctx, "acquire_key", time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, dl.SetExpiredTime("acquire_key", lock.Secret(), 10))
+	assert.NoError(t, lock.Unlock())
+}
+
+func TestAcquireLost(t *testing.T) {
+	dl, db := getTestDLock(t)
+
+	lock, err := dl.Acquire(context.Background(), "acquire_lost", 300*time.Millisecond)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Del("acquire_lost"))
+
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lost is not closed")
+	}
+	assert.Equal(t, context.Canceled, lock.Context().Err())
+	assert.Equal(t, ErrorLockLost, lock.Unlock())
+
+	// cancelling the context of Acquire stops renewing, but does not close Lost
+	ctx, cancel := context.WithCancel(context.Background())
+	lock, err = dl.Acquire(ctx, "acquire_cancel", 300*time.Millisecond)
+	assert.NoError(t, err)
+	cancel()
+	assert.Equal(t, context.Canceled, lock.Context().Err())
+	select {
+	case <-lock.Lost():
+		t.Fatal("lost is closed")
+	default:
+	}
+	assert.NoError(t, lock.Unlock())
+}
+
+// unreachableBackend takes locks but fails every renewal by a network error
+type unreachableBackend struct{}
+
+func (unreachableBackend) acquire(ctx context.Context, key, secret string, ttl time.Duration, fencing bool) (int64, error) {
+	return 0, nil
+}
+
+func (unreachableBackend) renew(ctx context.Context, key, secret string, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func (unreachableBackend) release(ctx context.Context, key, secret string) error {
+	return nil
+}
+
+func TestAcquireUnreachable(t *testing.T) {
+	ttl := 300 * time.Millisecond
+	start := time.Now()
+	lock, err := acquire(context.Background(), unreachableBackend{}, "acquire_unreachable", ttl, false)
+	assert.NoError(t, err)
+
+	// the lock is given up before it may expire on the server
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lost is not closed")
+	}
+	assert.True(t, time.Since(start) < ttl)
+	assert.Equal(t, context.Canceled, lock.Context().Err())
+	assert.Equal(t, ErrorLockLost, lock.Unlock())
+}
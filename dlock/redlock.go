@@ -0,0 +1,178 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	rediscm "github.com/chenjie4255/tools/redis"
+)
+
+// clockDriftFactor 各节点间时钟漂移占ttl的比例，另加2ms
+const clockDriftFactor = 0.01
+
+func clockDrift(ttl time.Duration) time.Duration {
+	return time.Duration(float64(ttl)*clockDriftFactor) + 2*time.Millisecond
+}
+
+// NewRedlock 在多个独立的redis实例上加锁，超过半数的实例加锁成功才算获得锁
+// hosts should be independent masters, not replicas of each other, so that a failover
+// of one of them can not hand the lock to another holder.
+func NewRedlock(hosts []string, password string, dbNum int, opts ...rediscm.PoolOption) DLock {
+	pools := make([]*redis.Pool, len(hosts))
+	for i, host := range hosts {
+		pools[i] = rediscm.NewPool(host, password, dbNum, opts...)
+	}
+	return NewRedlockWithPools(pools)
+}
+
+// NewRedlockWithPools 同NewRedlock，pools为空时panic
+func NewRedlockWithPools(pools []*redis.Pool) DLock {
+	if len(pools) == 0 {
+		panic("dlock: redlock needs at least one node")
+	}
+	return &redlock{pools, len(pools)/2 + 1}
+}
+
+type redlock struct {
+	pools  []*redis.Pool
+	quorum int
+}
+
+// each runs fn on all nodes at once, every node is bounded by timeout. It returns the
+// number of nodes fn succeeded and failed with an error, and the first error.
+func (r *redlock) each(ctx context.Context, timeout time.Duration, fn func(ctx context.Context, conn redis.Conn) (bool, error)) (ok, failed int, err error) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, pool := range r.pools {
+		wg.Add(1)
+		go func(pool *redis.Pool) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			succeeded, nodeErr := func() (bool, error) {
+				conn, err := pool.GetContext(ctx)
+				if err != nil {
+					return false, err
+				}
+				defer conn.Close()
+				return fn(ctx, conn)
+			}()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if nodeErr != nil {
+				failed++
+				if err == nil {
+					err = nodeErr
+				}
+			} else if succeeded {
+				ok++
+			}
+		}(pool)
+	}
+	wg.Wait()
+	return ok, failed, err
+}
+
+// nodeTimeout bounds the time spent on one node, so that a node down does not use up
+// the validity of the lock
+func nodeTimeout(ttl time.Duration) time.Duration {
+	if ttl < 10*time.Millisecond {
+		return time.Millisecond
+	}
+	return ttl / 10
+}
+
+func (r *redlock) acquire(ctx context.Context, key, secret string, ttl time.Duration, fencing bool) (int64, error) {
+	if fencing {
+		return 0, errors.New("fencing tokens are not supported by redlock")
+	}
+
+	start := time.Now()
+	ok, failed, err := r.each(ctx, nodeTimeout(ttl), func(ctx context.Context, conn redis.Conn) (bool, error) {
+		_, err := redis.String(redis.DoContext(conn, ctx, "SET", key, secret, "PX", ttl.Milliseconds(), "NX"))
+		if err == redis.ErrNil {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	validity := ttl - time.Since(start) - clockDrift(ttl)
+	if ok >= r.quorum && validity > 0 {
+		return 0, nil
+	}
+
+	// nodes which timed out may have set the key as well
+	r.release(context.Background(), key, secret)
+
+	if ok >= r.quorum {
+		return 0, ErrorTimeout
+	}
+	if ok+failed < len(r.pools) || err == nil {
+		return 0, ErrorLockHeld
+	}
+	return 0, err
+}
+
+// renew extends the lock on all nodes, it succeeds if a majority is extended in time
+func (r *redlock) renew(ctx context.Context, key, secret string, ttl time.Duration) error {
+	start := time.Now()
+	ok, failed, err := r.each(ctx, nodeTimeout(ttl), func(ctx context.Context, conn redis.Conn) (bool, error) {
+		args := rediscm.NewScriptArgs(key).String(secret).Millis(ttl)
+		ret, err := redis.Int(rediscm.PExpireIfEqualScript.RunConn(ctx, conn, args))
+		return ret == 1, err
+	})
+	if ok >= r.quorum && ttl-time.Since(start)-clockDrift(ttl) > 0 {
+		return nil
+	}
+	if ok+failed < r.quorum || err == nil {
+		return errNotOwner
+	}
+	return err
+}
+
+// release deletes the lock on all nodes, including the nodes it was not acquired on.
+// A node failing is ignored if the lock is released on a majority.
+func (r *redlock) release(ctx context.Context, key, secret string) error {
+	ok, failed, err := r.each(ctx, time.Second, func(ctx context.Context, conn redis.Conn) (bool, error) {
+		ret, err := redis.Int(rediscm.DelIfEqualScript.RunConn(ctx, conn, rediscm.NewScriptArgs(key).String(secret)))
+		return ret == 1, err
+	})
+	if ok >= r.quorum || (ok > 0 && failed == 0) {
+		return nil
+	}
+	if failed > 0 {
+		return err
+	}
+	return errNotOwner
+}
+
+func (r *redlock) GetLock(key string, seconds int) (string, error) {
+	secret := randomValue()
+	if _, err := r.acquire(context.Background(), key, secret, time.Duration(seconds)*time.Second, false); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (r *redlock) GetLockWait(key string, seconds int, timeout time.Duration) (string, error) {
+	return getLockWait(r, key, seconds, timeout)
+}
+
+func (r *redlock) DelLock(key string, secret string) error {
+	return r.release(context.Background(), key, secret)
+}
+
+func (r *redlock) SetExpiredTime(key, secret string, seconds int) error {
+	return r.renew(context.Background(), key, secret, time.Duration(seconds)*time.Second)
+}
+
+// Acquire 同dlock的Acquire，续期时超过半数的节点续期成功才算成功
+func (r *redlock) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	return acquire(ctx, r, key, ttl, false)
+}
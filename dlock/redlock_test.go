@@ -0,0 +1,126 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rediscm "github.com/chenjie4255/tools/redis"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// getTestRedlock uses databases of the test server as independent nodes
+func getTestRedlock(t *testing.T) (DLock, []rediscm.DB) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	pools := []*redis.Pool{}
+	dbs := []rediscm.DB{}
+	for _, dbNum := range []int{3, 4, 5} {
+		rediscm.FlushDB(env.RedisHost, "", dbNum)
+		pool := rediscm.NewPool(env.RedisHost, "", dbNum)
+		pools = append(pools, pool)
+		dbs = append(dbs, rediscm.NewDBFromPool(pool))
+	}
+	return NewRedlockWithPools(pools), dbs
+}
+
+func TestRedlock(t *testing.T) {
+	dl, dbs := getTestRedlock(t)
+
+	secret, err := dl.GetLock("redlock_key", 10)
+	assert.NoError(t, err)
+	for _, db := range dbs {
+		exists, _ := db.Exists("redlock_key")
+		assert.True(t, exists)
+	}
+	_, err = dl.GetLock("redlock_key", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+	assert.NoError(t, dl.SetExpiredTime("redlock_key", secret, 20))
+	assert.Error(t, dl.SetExpiredTime("redlock_key", "other", 20))
+	assert.NoError(t, dl.DelLock("redlock_key", secret))
+	assert.Error(t, dl.DelLock("redlock_key", secret))
+
+	// a minority of the nodes held by others
+	assert.NoError(t, dbs[0].Set("redlock_minority", "other", 10))
+	secret, err = dl.GetLock("redlock_minority", 10)
+	assert.NoError(t, err)
+	assert.NoError(t, dl.DelLock("redlock_minority", secret))
+	exists, _ := dbs[0].Exists("redlock_minority")
+	assert.True(t, exists)
+
+	// a majority of the nodes held by others, the acquired node is released again
+	assert.NoError(t, dbs[0].Set("redlock_majority", "other", 10))
+	assert.NoError(t, dbs[1].Set("redlock_majority", "other", 10))
+	_, err = dl.GetLock("redlock_majority", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+	exists, _ = dbs[2].Exists("redlock_majority")
+	assert.False(t, exists)
+
+	start := time.Now()
+	_, err = dl.GetLockWait("redlock_majority", 10, 300*time.Millisecond)
+	assert.Equal(t, ErrorTimeout, err)
+	assert.True(t, time.Since(start) <= 400*time.Millisecond)
+
+	lock, err := dl.Acquire(context.Background(), "redlock_acquire", 300*time.Millisecond)
+	assert.NoError(t, err)
+	time.Sleep(time.Second)
+	assert.NoError(t, lock.Context().Err())
+	assert.NoError(t, lock.Unlock())
+}
+
+func TestRedlockNodeDown(t *testing.T) {
+	_, dbs := getTestRedlock(t)
+	env := testenv.GetIntegratedTestEnv()
+
+	pools := []*redis.Pool{
+		rediscm.NewPool(env.RedisHost, "", 3),
+		rediscm.NewPool(env.RedisHost, "", 4),
+		rediscm.NewPool("127.0.0.1:1", "", 0),
+	}
+	dl := NewRedlockWithPools(pools)
+	secret, err := dl.GetLock("redlock_down", 10)
+	assert.NoError(t, err)
+	assert.NoError(t, dl.SetExpiredTime("redlock_down", secret, 10))
+	assert.NoError(t, dl.DelLock("redlock_down", secret))
+	exists, _ := dbs[0].Exists("redlock_down")
+	assert.False(t, exists)
+
+	// no majority without a second node
+	dl = NewRedlockWithPools(append(pools[1:], rediscm.NewPool("127.0.0.1:1", "", 0)))
+	_, err = dl.GetLock("redlock_down", 10)
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrorLockHeld, err)
+	exists, _ = dbs[1].Exists("redlock_down")
+	assert.False(t, exists)
+}
+
+func TestRedlockNodes(t *testing.T) {
+	assert.Panics(t, func() {
+		NewRedlockWithPools(nil)
+	})
+	assert.Panics(t, func() {
+		NewRedlock(nil, "", 0)
+	})
+	// no lock without a quorum, even if there is no node
+	_, err := (&redlock{quorum: 1}).GetLock("redlock_nodes", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+
+	_, dbs := getTestRedlock(t)
+	env := testenv.GetIntegratedTestEnv()
+	dl := NewRedlock([]string{env.RedisHost}, "", 3)
+	secret, err := dl.GetLock("redlock_nodes", 10)
+	assert.NoError(t, err)
+	exists, _ := dbs[0].Exists("redlock_nodes")
+	assert.True(t, exists)
+	_, err = dl.GetLock("redlock_nodes", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+	assert.NoError(t, dl.DelLock("redlock_nodes", secret))
+}
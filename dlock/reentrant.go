@@ -0,0 +1,194 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/chenjie4255/tools/log"
+	rediscm "github.com/chenjie4255/tools/redis"
+)
+
+// ReentrantLock 可重入锁，同一个owner可以多次获得同一个key，释放同样的次数后锁才被释放
+// The lock is a hash of owner to hold count, so its keys can not be shared with DLock.
+type ReentrantLock interface {
+	// Lock 获得锁并返回owner持有的次数，每次获得都会把过期时间重置为seconds
+	// ErrorLockHeld is returned if the key is held by another owner.
+	Lock(key, owner string, seconds int) (int, error)
+	// LockWait 等待直到获得锁或ctx结束，不支持WaitOptions.Fair
+	LockWait(ctx context.Context, key, owner string, seconds int, opts WaitOptions) (int, error)
+	// Unlock 释放一次并返回剩余的持有次数，为0时锁已释放
+	Unlock(key, owner string) (int, error)
+	SetExpiredTime(key, owner string, seconds int) error
+}
+
+// NewOwnerID 生成随机的owner ID
+func NewOwnerID() string {
+	return randomValue()
+}
+
+// NewReentrant 生成ReentrantLock，opts用于设置连接池，参见redis.NewPool
+func NewReentrant(host, password string, dbNum int, opts ...rediscm.PoolOption) ReentrantLock {
+	pool := rediscm.NewPool(host, password, dbNum, opts...)
+	return &reentrantLock{pool}
+}
+
+func NewReentrantWithPool(pool *redis.Pool) ReentrantLock {
+	return &reentrantLock{pool}
+}
+
+type reentrantLock struct {
+	pool *redis.Pool
+}
+
+// doScript runs scr on a connection of pool
+func doScript(ctx context.Context, pool *redis.Pool, scr *rediscm.Script, args *rediscm.ScriptArgs) (interface{}, error) {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return scr.RunConn(ctx, conn, args)
+}
+
+// reentrantLockScript KEYS[1] hash of owner to count, ARGV[1] owner, ARGV[2] ttl ms,
+// ARGV[3] optional field marking the hold as taken by one attempt of LockWait
+// returns the hold count or 0 if the lock is held by another owner
+var reentrantLockScript = rediscm.RegisterScript("dlock.reentrant_lock", 1, `if redis.call("EXISTS", KEYS[1]) == 0 or redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+local count = redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+if ARGV[3] then
+redis.call("HSET", KEYS[1], ARGV[3], 1)
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return count
+end
+return 0`)
+
+// reentrantAbortScript KEYS[1] hash of owner to count, ARGV[1] owner, ARGV[2] attempt field
+// undoes the hold taken by the attempt, returns the count left or -1 if there is none
+var reentrantAbortScript = rediscm.RegisterScript("dlock.reentrant_abort", 1, `if redis.call("HDEL", KEYS[1], ARGV[2]) == 0 then
+return -1
+end
+local count = redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+if count <= 0 then
+redis.call("DEL", KEYS[1])
+return 0
+end
+return count`)
+
+// reentrantUnlockScript KEYS[1] hash of owner to count, ARGV[1] owner
+// returns the count left or -1 if the lock is not held by the owner
+var reentrantUnlockScript = rediscm.RegisterScript("dlock.reentrant_unlock", 1, `if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 0 then
+return -1
+end
+local count = redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+if count <= 0 then
+redis.call("DEL", KEYS[1])
+return 0
+end
+return count`)
+
+// reentrantExpireScript KEYS[1] hash of owner to count, ARGV[1] owner, ARGV[2] ttl ms
+var reentrantExpireScript = rediscm.RegisterScript("dlock.reentrant_expire", 1, `if redis.call("HEXISTS", KEYS[1], ARGV[1]) == 1 then
+return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+func (l *reentrantLock) lock(ctx context.Context, key, owner string, seconds int, attempt string) (int, error) {
+	args := rediscm.NewScriptArgs(key).String(owner).Millis(time.Duration(seconds) * time.Second)
+	if attempt != "" {
+		args.String(attempt)
+	}
+	count, err := redis.Int(doScript(ctx, l.pool, reentrantLockScript, args))
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrorLockHeld
+	}
+	return count, nil
+}
+
+func (l *reentrantLock) Lock(key, owner string, seconds int) (int, error) {
+	return l.lock(context.Background(), key, owner, seconds, "")
+}
+
+// attemptField marks the hold taken by one LockWait call, so that it can be undone
+// if the call is interrupted without knowing whether it got the hold
+func attemptField(owner string) string {
+	return owner + ":attempt:" + randomValue()
+}
+
+func (l *reentrantLock) LockWait(ctx context.Context, key, owner string, seconds int, opts WaitOptions) (int, error) {
+	if opts.Fair {
+		return 0, errors.New("fair waiting is not supported by reentrant locks")
+	}
+
+	count := 0
+	attempt := attemptField(owner)
+	err := waitLock(ctx, opts, func(ctx context.Context) (bool, error) {
+		var err error
+		count, err = l.lock(ctx, key, owner, seconds, attempt)
+		return false, err
+	}, func() {
+		l.abort(key, owner, attempt)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// the hold is known to the caller now, the mark is not needed any more
+	l.clearAttempt(key, attempt)
+	return count, nil
+}
+
+func (l *reentrantLock) clearAttempt(key, attempt string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if _, err := redis.DoContext(conn, ctx, "HDEL", key, attempt); err != nil {
+		logger.AddFile().WithFields(log.Fields{"key": key, "error": err.Error()}).Warn("failed to clear lock attempt")
+	}
+}
+
+// abort undoes the hold taken by attempt, if the interrupted call got one
+func (l *reentrantLock) abort(key, owner, attempt string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := doScript(ctx, l.pool, reentrantAbortScript, rediscm.NewScriptArgs(key).String(owner).String(attempt)); err != nil {
+		logger.AddFile().WithFields(log.Fields{"key": key, "error": err.Error()}).Warn("failed to undo abandoned lock attempt")
+	}
+}
+
+func (l *reentrantLock) Unlock(key, owner string) (int, error) {
+	count, err := redis.Int(doScript(context.Background(), l.pool, reentrantUnlockScript, rediscm.NewScriptArgs(key).String(owner)))
+	if err != nil {
+		return 0, err
+	}
+	if count < 0 {
+		return 0, errNotOwner
+	}
+	return count, nil
+}
+
+func (l *reentrantLock) SetExpiredTime(key, owner string, seconds int) error {
+	args := rediscm.NewScriptArgs(key).String(owner).Millis(time.Duration(seconds) * time.Second)
+	ret, err := redis.Int(doScript(context.Background(), l.pool, reentrantExpireScript, args))
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return errNotOwner
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReentrantLock(t *testing.T) {
+	_, db := getTestDLock(t)
+	env := testenv.GetIntegratedTestEnv()
+	l := NewReentrant(env.RedisHost, "", 3)
+	owner := NewOwnerID()
+
+	count, err := l.Lock("reentrant_key", owner, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = l.Lock("reentrant_key", owner, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, err = l.Lock("reentrant_key", "other", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+	assert.NoError(t, l.SetExpiredTime("reentrant_key", owner, 20))
+	assert.Error(t, l.SetExpiredTime("reentrant_key", "other", 20))
+	_, err = l.Unlock("reentrant_key", "other")
+	assert.Error(t, err)
+
+	count, err = l.Unlock("reentrant_key", owner)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	exists, _ := db.Exists("reentrant_key")
+	assert.True(t, exists)
+	count, err = l.Unlock("reentrant_key", owner)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	exists, _ = db.Exists("reentrant_key")
+	assert.False(t, exists)
+	_, err = l.Unlock("reentrant_key", owner)
+	assert.Error(t, err)
+
+	_, err = l.Lock("reentrant_key", owner, 10)
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l.Unlock("reentrant_key", owner)
+	}()
+	count, err = l.LockWait(context.Background(), "reentrant_key", "other", 10, WaitOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	// the mark of the attempt is cleared
+	conn := db.Pool().Get()
+	defer conn.Close()
+	fields, err := redis.Int(conn.Do("HLEN", "reentrant_key"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fields)
+	count, err = l.Unlock("reentrant_key", "other")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestReentrantLockAbort(t *testing.T) {
+	_, db := getTestDLock(t)
+	env := testenv.GetIntegratedTestEnv()
+	l := NewReentrant(env.RedisHost, "", 3).(*reentrantLock)
+	owner := NewOwnerID()
+
+	_, err := l.Lock("reentrant_abort", owner, 10)
+	assert.NoError(t, err)
+
+	// an interrupted LockWait whose hold was taken undoes exactly that hold
+	attempt := attemptField(owner)
+	count, err := l.lock(context.Background(), "reentrant_abort", owner, 10, attempt)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	l.abort("reentrant_abort", owner, attempt)
+	l.abort("reentrant_abort", owner, attempt)
+
+	count, err = l.Unlock("reentrant_abort", owner)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	exists, _ := db.Exists("reentrant_abort")
+	assert.False(t, exists)
+
+	// an attempt which took nothing undoes nothing
+	_, err = l.Lock("reentrant_abort", owner, 10)
+	assert.NoError(t, err)
+	l.abort("reentrant_abort", owner, attemptField(owner))
+	count, err = l.Unlock("reentrant_abort", owner)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
@@ -0,0 +1,182 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	rediscm "github.com/chenjie4255/tools/redis"
+)
+
+// RWLock 分布式读写锁，可同时有多个读者，或只有一个写者
+// Every holder gets its own secret and expires on its own, a reader gone does not keep
+// the lock once its seconds pass. Readers keep coming may starve writers.
+type RWLock interface {
+	// RLock 获得读锁，被写者持有时返回ErrorLockHeld
+	RLock(key string, seconds int) (string, error)
+	// Lock 获得写锁，被读者或写者持有时返回ErrorLockHeld
+	Lock(key string, seconds int) (string, error)
+	// RLockWait 和LockWait等待直到获得锁或ctx结束，不支持WaitOptions.Fair
+	RLockWait(ctx context.Context, key string, seconds int, opts WaitOptions) (string, error)
+	LockWait(ctx context.Context, key string, seconds int, opts WaitOptions) (string, error)
+	// Unlock 释放读锁或写锁
+	Unlock(key, secret string) error
+	SetExpiredTime(key, secret string, seconds int) error
+}
+
+// NewRWLock 生成RWLock，opts用于设置连接池，参见redis.NewPool
+func NewRWLock(host, password string, dbNum int, opts ...rediscm.PoolOption) RWLock {
+	pool := rediscm.NewPool(host, password, dbNum, opts...)
+	return &rwLock{pool}
+}
+
+func NewRWLockWithPool(pool *redis.Pool) RWLock {
+	return &rwLock{pool}
+}
+
+type rwLock struct {
+	pool *redis.Pool
+}
+
+const (
+	rwModeRead  = "read"
+	rwModeWrite = "write"
+)
+
+// rwLockScript keeps the mode in the field "mode" of the hash KEYS[1], and the deadline
+// in ms of every holder in the field of its secret. Holders past their deadline are dropped.
+// ARGV[1] mode, ARGV[2] secret, ARGV[3] ttl ms, returns 1 if locked or 0
+var rwLockScript = rediscm.RegisterScript("dlock.rw_lock", 1, `redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local fields = redis.call("HGETALL", KEYS[1])
+local holders = 0
+for i = 1, #fields, 2 do
+	if fields[i] ~= "mode" then
+		if tonumber(fields[i + 1]) <= now then
+			redis.call("HDEL", KEYS[1], fields[i])
+		else
+			holders = holders + 1
+		end
+	end
+end
+local mode = redis.call("HGET", KEYS[1], "mode")
+if holders > 0 and not (mode == "read" and ARGV[1] == "read") then
+	return 0
+end
+local ttl = tonumber(ARGV[3])
+redis.call("HSET", KEYS[1], "mode", ARGV[1], ARGV[2], now + ttl)
+if redis.call("PTTL", KEYS[1]) < ttl then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1`)
+
+// rwUnlockScript KEYS[1] hash of rwLockScript, ARGV[1] secret, returns 1 if released or 0
+var rwUnlockScript = rediscm.RegisterScript("dlock.rw_unlock", 1, `redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local deadline = tonumber(redis.call("HGET", KEYS[1], ARGV[1]))
+if not deadline then
+	return 0
+end
+redis.call("HDEL", KEYS[1], ARGV[1])
+if redis.call("HLEN", KEYS[1]) <= 1 then
+	redis.call("DEL", KEYS[1])
+end
+if deadline <= now then
+	return 0
+end
+return 1`)
+
+// rwExpireScript KEYS[1] hash of rwLockScript, ARGV[1] secret, ARGV[2] ttl ms, returns 1 or 0
+var rwExpireScript = rediscm.RegisterScript("dlock.rw_expire", 1, `redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local deadline = tonumber(redis.call("HGET", KEYS[1], ARGV[1]))
+if not deadline or deadline <= now then
+	return 0
+end
+local ttl = tonumber(ARGV[2])
+redis.call("HSET", KEYS[1], ARGV[1], now + ttl)
+if redis.call("PTTL", KEYS[1]) < ttl then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1`)
+
+func (l *rwLock) lock(ctx context.Context, mode, key, secret string, seconds int) error {
+	args := rediscm.NewScriptArgs(key).String(mode).String(secret).Millis(time.Duration(seconds) * time.Second)
+	ret, err := redis.Int(doScript(ctx, l.pool, rwLockScript, args))
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return ErrorLockHeld
+	}
+	return nil
+}
+
+func (l *rwLock) lockWait(ctx context.Context, mode, key string, seconds int, opts WaitOptions) (string, error) {
+	if opts.Fair {
+		return "", errors.New("fair waiting is not supported by rw locks")
+	}
+
+	secret := randomValue()
+	err := waitLock(ctx, opts, func(ctx context.Context) (bool, error) {
+		return false, l.lock(ctx, mode, key, secret, seconds)
+	}, func() {
+		l.Unlock(key, secret)
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (l *rwLock) RLock(key string, seconds int) (string, error) {
+	secret := randomValue()
+	if err := l.lock(context.Background(), rwModeRead, key, secret, seconds); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (l *rwLock) Lock(key string, seconds int) (string, error) {
+	secret := randomValue()
+	if err := l.lock(context.Background(), rwModeWrite, key, secret, seconds); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+func (l *rwLock) RLockWait(ctx context.Context, key string, seconds int, opts WaitOptions) (string, error) {
+	return l.lockWait(ctx, rwModeRead, key, seconds, opts)
+}
+
+func (l *rwLock) LockWait(ctx context.Context, key string, seconds int, opts WaitOptions) (string, error) {
+	return l.lockWait(ctx, rwModeWrite, key, seconds, opts)
+}
+
+func (l *rwLock) Unlock(key, secret string) error {
+	ret, err := redis.Int(doScript(context.Background(), l.pool, rwUnlockScript, rediscm.NewScriptArgs(key).String(secret)))
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return errNotOwner
+	}
+	return nil
+}
+
+func (l *rwLock) SetExpiredTime(key, secret string, seconds int) error {
+	args := rediscm.NewScriptArgs(key).String(secret).Millis(time.Duration(seconds) * time.Second)
+	ret, err := redis.Int(doScript(context.Background(), l.pool, rwExpireScript, args))
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return errNotOwner
+	}
+	return nil
+}
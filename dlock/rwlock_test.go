@@ -0,0 +1,71 @@
+package dlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRWLock(t *testing.T) {
+	_, db := getTestDLock(t)
+	env := testenv.GetIntegratedTestEnv()
+	l := NewRWLock(env.RedisHost, "", 3)
+
+	r1, err := l.RLock("rw_key", 10)
+	assert.NoError(t, err)
+	r2, err := l.RLock("rw_key", 10)
+	assert.NoError(t, err)
+	_, err = l.Lock("rw_key", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+
+	assert.NoError(t, l.Unlock("rw_key", r1))
+	assert.Error(t, l.Unlock("rw_key", r1))
+	_, err = l.Lock("rw_key", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+	assert.NoError(t, l.SetExpiredTime("rw_key", r2, 10))
+	assert.NoError(t, l.Unlock("rw_key", r2))
+	exists, _ := db.Exists("rw_key")
+	assert.False(t, exists)
+
+	w, err := l.Lock("rw_key", 10)
+	assert.NoError(t, err)
+	_, err = l.RLock("rw_key", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+	_, err = l.Lock("rw_key", 10)
+	assert.Equal(t, ErrorLockHeld, err)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		l.Unlock("rw_key", w)
+	}()
+	r1, err = l.RLockWait(context.Background(), "rw_key", 10, WaitOptions{})
+	assert.NoError(t, err)
+
+	// a reader gone expires on its own while the other reader renews
+	r2, err = l.RLock("rw_key", 1)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Unlock("rw_key", r1))
+	time.Sleep(1100 * time.Millisecond)
+	assert.Error(t, l.SetExpiredTime("rw_key", r2, 10))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	w, err = l.LockWait(ctx, "rw_key", 10, WaitOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, l.Unlock("rw_key", w))
+
+	// a reader past its deadline is dropped even if the key lives on
+	r1, err = l.RLock("rw_key", 10)
+	assert.NoError(t, err)
+	r2, err = l.RLock("rw_key", 1)
+	assert.NoError(t, err)
+	time.Sleep(1100 * time.Millisecond)
+	assert.Error(t, l.Unlock("rw_key", r2))
+	assert.NoError(t, l.Unlock("rw_key", r1))
+	w, err = l.Lock("rw_key", 10)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Unlock("rw_key", w))
+}
@@ -0,0 +1,263 @@
+package dlock
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/chenjie4255/tools/log"
+	rediscm "github.com/chenjie4255/tools/redis"
+)
+
+const (
+	defaultMinBackoff = 10 * time.Millisecond
+	defaultMaxBackoff = time.Second
+)
+
+// WaitOptions 等待锁的方式
+type WaitOptions struct {
+	// MinBackoff 第一次重试前等待的时间，之后每次翻倍，默认10ms
+	MinBackoff time.Duration
+	// MaxBackoff 重试间隔的上限，默认1s
+	MaxBackoff time.Duration
+	// Fair 为true时等待者排队，按顺序获得锁
+	// Waiters which stop retrying leave the queue after 3*MaxBackoff. GetLock and the
+	// waiters which are not fair do not queue and may still take the lock first.
+	Fair bool
+}
+
+func (o *WaitOptions) setDefaults() {
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = defaultMinBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultMaxBackoff
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = o.MinBackoff
+	}
+}
+
+// queueTimeout is the time a fair waiter is kept in the queue without retrying
+func (o *WaitOptions) queueTimeout() time.Duration {
+	return 3 * o.MaxBackoff
+}
+
+// jitterRand is seeded by time, so that processes started together do not retry in step
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// jitter returns a random duration in [d/2, d]
+func jitter(d time.Duration) time.Duration {
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+	return d/2 + time.Duration(jitterRand.Int63n(int64(d/2)+1))
+}
+
+// waitLock calls try until it succeeds or fails with an error other than ErrorLockHeld.
+// try reports whether the waiter is the next in the queue, which retries at MinBackoff.
+// If try fails because ctx is done the lock may have been taken anyway, abort is called
+// to release it.
+func waitLock(ctx context.Context, opts WaitOptions, try func(ctx context.Context) (next bool, err error), abort func()) error {
+	opts.setDefaults()
+
+	backoff := opts.MinBackoff
+	for {
+		next, err := try(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			abort()
+			return ctxErr
+		}
+		if err != ErrorLockHeld {
+			abort()
+			return err
+		}
+
+		delay := backoff
+		if next {
+			delay = opts.MinBackoff
+		}
+		timer := time.NewTimer(jitter(delay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			abort()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
+
+// fairLockScript takes KEYS[1] in the order of the queue KEYS[2], KEYS[3] keeps the
+// deadline of every waiter so that waiters gone are dropped from the queue.
+// ARGV[1] secret, ARGV[2] ttl ms, ARGV[3] queue timeout ms
+// returns 1 if locked, 2 if the waiter is the next in the queue, otherwise 0
+var fairLockScript = rediscm.RegisterScript("dlock.fair_lock", 3, `redis.replicate_commands()
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+while true do
+	local first = redis.call("LINDEX", KEYS[2], 0)
+	if not first or first == ARGV[1] then
+		break
+	end
+	local deadline = tonumber(redis.call("ZSCORE", KEYS[3], first))
+	if deadline and deadline > now then
+		break
+	end
+	redis.call("LPOP", KEYS[2])
+	redis.call("ZREM", KEYS[3], first)
+end
+local first = redis.call("LINDEX", KEYS[2], 0)
+if redis.call("EXISTS", KEYS[1]) == 0 and (not first or first == ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	if first then
+		redis.call("LPOP", KEYS[2])
+	end
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	return 1
+end
+if not redis.call("ZSCORE", KEYS[3], ARGV[1]) then
+	redis.call("RPUSH", KEYS[2], ARGV[1])
+	first = first or ARGV[1]
+end
+redis.call("ZADD", KEYS[3], now + tonumber(ARGV[3]), ARGV[1])
+redis.call("PEXPIRE", KEYS[2], ARGV[3])
+redis.call("PEXPIRE", KEYS[3], ARGV[3])
+if first == ARGV[1] then
+	return 2
+end
+return 0`)
+
+// leaveQueueScript removes the waiter ARGV[1] from the queue of fairLockScript
+var leaveQueueScript = rediscm.RegisterScript("dlock.leave_queue", 2, `redis.call("LREM", KEYS[1], 0, ARGV[1])
+return redis.call("ZREM", KEYS[2], ARGV[1])`)
+
+// queueKeys uses key as the hash tag, see fencingKey
+func queueKeys(key string) (queue, timeouts string) {
+	return "{" + key + "}.queue", "{" + key + "}.queue_timeouts"
+}
+
+func (l *dlock) tryFairLock(ctx context.Context, key, secret string, ttl, queueTimeout time.Duration) (bool, error) {
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	queue, timeouts := queueKeys(key)
+	args := rediscm.NewScriptArgs(key, queue, timeouts).String(secret).Millis(ttl).Millis(queueTimeout)
+	ret, err := redis.Int(fairLockScript.RunConn(ctx, conn, args))
+	if err != nil {
+		return false, err
+	}
+	switch ret {
+	case 1:
+		return false, nil
+	case 2:
+		return true, ErrorLockHeld
+	default:
+		return false, ErrorLockHeld
+	}
+}
+
+// abandon releases a lock which may have been taken by an interrupted waiter and leaves the queue
+func (l *dlock) abandon(key, secret string, fair bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	conn, err := l.pool.GetContext(ctx)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if fair {
+		queue, timeouts := queueKeys(key)
+		if _, err := leaveQueueScript.RunConn(ctx, conn, rediscm.NewScriptArgs(queue, timeouts).String(secret)); err != nil {
+			logger.AddFile().WithFields(log.Fields{"key": key, "error": err.Error()}).Warn("failed to leave lock queue")
+		}
+	}
+	if _, err := rediscm.DelIfEqualScript.RunConn(ctx, conn, rediscm.NewScriptArgs(key).String(secret)); err != nil {
+		logger.AddFile().WithFields(log.Fields{"key": key, "error": err.Error()}).Warn("failed to release abandoned lock")
+	}
+}
+
+// WaitLock 等待直到获得锁，ctx结束时返回ctx.Err()，不会留下之后才获得的锁
+func (l *dlock) WaitLock(ctx context.Context, key string, seconds int, opts WaitOptions) (string, error) {
+	opts.setDefaults()
+	secret := randomValue()
+	ttl := time.Duration(seconds) * time.Second
+
+	err := waitLock(ctx, opts, func(ctx context.Context) (bool, error) {
+		if opts.Fair {
+			return l.tryFairLock(ctx, key, secret, ttl, opts.queueTimeout())
+		}
+		_, err := l.acquire(ctx, key, secret, ttl, false)
+		return false, err
+	}, func() {
+		l.abandon(key, secret, opts.Fair)
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// abandon releases the nodes which may have been taken by an interrupted waiter
+func (r *redlock) abandon(key, secret string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// errNotOwner: no node was taken, nothing to release
+	if err := r.release(ctx, key, secret); err != nil && err != errNotOwner {
+		logger.AddFile().WithFields(log.Fields{"key": key, "error": err.Error()}).Warn("failed to release abandoned lock")
+	}
+}
+
+// WaitLock 同dlock的WaitLock，不支持Fair
+func (r *redlock) WaitLock(ctx context.Context, key string, seconds int, opts WaitOptions) (string, error) {
+	if opts.Fair {
+		return "", errors.New("fair waiting is not supported by redlock")
+	}
+
+	secret := randomValue()
+	ttl := time.Duration(seconds) * time.Second
+	err := waitLock(ctx, opts, func(ctx context.Context) (bool, error) {
+		_, err := r.acquire(ctx, key, secret, ttl, false)
+		if err == ErrorTimeout {
+			err = ErrorLockHeld
+		}
+		return false, err
+	}, func() {
+		r.abandon(key, secret)
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// getLockWait runs WaitLock of dl with timeout, ErrorTimeout is returned when it is reached
+func getLockWait(dl DLock, key string, seconds int, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	secret, err := dl.WaitLock(ctx, key, seconds, WaitOptions{})
+	if err == context.DeadlineExceeded {
+		return "", ErrorTimeout
+	}
+	return secret, err
+}
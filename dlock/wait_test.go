@@ -0,0 +1,106 @@
+package dlock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLockWait(t *testing.T) {
+	dl, db := getTestDLock(t)
+
+	secret, err := dl.GetLock("wait_key", 10)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = dl.GetLockWait("wait_key", 10, 200*time.Millisecond)
+	assert.Equal(t, ErrorTimeout, err)
+	assert.True(t, time.Since(start) < 300*time.Millisecond)
+
+	// nothing keeps trying after the timeout
+	assert.NoError(t, dl.DelLock("wait_key", secret))
+	time.Sleep(300 * time.Millisecond)
+	exists, _ := db.Exists("wait_key")
+	assert.False(t, exists)
+
+	secret, err = dl.GetLock("wait_key", 10)
+	assert.NoError(t, err)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		dl.DelLock("wait_key", secret)
+	}()
+	secret, err = dl.GetLockWait("wait_key", 10, time.Second)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, secret)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	_, err = dl.WaitLock(ctx, "wait_key", 10, WaitOptions{})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestWaitLockFair(t *testing.T) {
+	dl, db := getTestDLock(t)
+	opts := WaitOptions{MinBackoff: 5 * time.Millisecond, MaxBackoff: 100 * time.Millisecond, Fair: true}
+
+	secret, err := dl.WaitLock(context.Background(), "fair_key", 10, opts)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	order := []int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			secret, err := dl.WaitLock(context.Background(), "fair_key", 10, opts)
+			if !assert.NoError(t, err) {
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			assert.NoError(t, dl.DelLock("fair_key", secret))
+		}(i)
+		// waiters are queued in order
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	assert.NoError(t, dl.DelLock("fair_key", secret))
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2}, order)
+
+	// a waiter giving up leaves the queue
+	secret, err = dl.WaitLock(context.Background(), "fair_key", 10, opts)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = dl.WaitLock(ctx, "fair_key", 10, opts)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	queue, _ := queueKeys("fair_key")
+	exists, _ := db.Exists(queue)
+	assert.False(t, exists)
+	assert.NoError(t, dl.DelLock("fair_key", secret))
+
+	// a waiter gone without leaving is dropped after the queue timeout
+	secret, err = dl.GetLock("fair_key", 10)
+	assert.NoError(t, err)
+	next, err := dl.(*dlock).tryFairLock(context.Background(), "fair_key", "ghost", 10*time.Second, 100*time.Millisecond)
+	assert.True(t, next)
+	assert.Equal(t, ErrorLockHeld, err)
+	assert.NoError(t, dl.DelLock("fair_key", secret))
+
+	start := time.Now()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = dl.WaitLock(ctx, "fair_key", 10, opts)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
@@ -0,0 +1,88 @@
+package mongohelper
+
+import (
+	"context"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrorStaleToken 更新携带的fencing token比文档中记录的旧，tag为errcode.ResExpired
+var ErrorStaleToken = errors.NewWithTag("fencing token is stale", errcode.ResExpired)
+
+// TokenFilter 在filter上加上条件：文档没有field或field不大于token
+func TokenFilter(filter bson.M, field string, token int64) bson.M {
+	cond := bson.M{"$or": bson.A{
+		bson.M{field: bson.M{"$exists": false}},
+		bson.M{field: bson.M{"$lte": token}},
+	}}
+	if len(filter) == 0 {
+		return cond
+	}
+	return bson.M{"$and": bson.A{filter, cond}}
+}
+
+// TokenUpdate 返回在$set中写入field为token的update，不修改原update
+// A $set of other types, such as a struct, is converted by bson into a bson.M first.
+func TokenUpdate(update bson.M, field string, token int64) (bson.M, error) {
+	ret := bson.M{}
+	for op, v := range update {
+		ret[op] = v
+	}
+
+	set := bson.M{}
+	switch v := update["$set"].(type) {
+	case nil:
+	case bson.M:
+		for name, value := range v {
+			set[name] = value
+		}
+	case map[string]interface{}:
+		for name, value := range v {
+			set[name] = value
+		}
+	case bson.D:
+		for _, e := range v {
+			set[e.Key] = e.Value
+		}
+	default:
+		data, err := bson.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := bson.Unmarshal(data, &set); err != nil {
+			return nil, err
+		}
+	}
+	set[field] = token
+	ret["$set"] = set
+	return ret, nil
+}
+
+// UpdateOneWithToken 只在token不比文档中field记录的旧时执行UpdateOne，并把field更新为token
+// ErrorStaleToken is returned if the document matched by filter carries a newer token,
+// a result with MatchedCount 0 and no error means there is no such document.
+func UpdateOneWithToken(ctx context.Context, coll *mongo.Collection, filter, update bson.M, field string, token int64) (*mongo.UpdateResult, error) {
+	tokenUpdate, err := TokenUpdate(update, field, token)
+	if err != nil {
+		return nil, err
+	}
+	result, err := coll.UpdateOne(ctx, TokenFilter(filter, field, token), tokenUpdate)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount > 0 {
+		return result, nil
+	}
+
+	count, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrorStaleToken
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package mongohelper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestTokenFilter(t *testing.T) {
+	cond := bson.M{"$or": bson.A{
+		bson.M{"token": bson.M{"$exists": false}},
+		bson.M{"token": bson.M{"$lte": int64(3)}},
+	}}
+	assert.Equal(t, cond, TokenFilter(nil, "token", 3))
+	assert.Equal(t, bson.M{"$and": bson.A{bson.M{"_id": 1}, cond}}, TokenFilter(bson.M{"_id": 1}, "token", 3))
+}
+
+func TestTokenUpdate(t *testing.T) {
+	update := bson.M{"$set": bson.M{"name": "a"}, "$inc": bson.M{"n": 1}}
+	ret, err := TokenUpdate(update, "token", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{
+		"$set": bson.M{"name": "a", "token": int64(3)},
+		"$inc": bson.M{"n": 1},
+	}, ret)
+	// the update is not changed
+	assert.Equal(t, bson.M{"name": "a"}, update["$set"])
+
+	ret, err = TokenUpdate(nil, "token", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$set": bson.M{"token": int64(3)}}, ret)
+
+	// a struct keeps its fields
+	doc := struct {
+		Name  string `bson:"name"`
+		Count int32  `bson:"count"`
+	}{"a", 2}
+	ret, err = TokenUpdate(bson.M{"$set": doc}, "token", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, bson.M{"$set": bson.M{"name": "a", "count": int32(2), "token": int64(3)}}, ret)
+
+	_, err = TokenUpdate(bson.M{"$set": 1}, "token", 3)
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/chenjie4255/tools/log"
+	"github.com/chenjie4255/tools/middleware"
+	"github.com/chenjie4255/tools/web"
+)
+
+// KeyFunc 从请求中取得限流的key，返回空字符串时不限流
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP 按真实IP限流，没有代理头时使用RemoteAddr
+func KeyByIP(r *http.Request) string {
+	if ip := middleware.RealIPFromRequest(r); ip != "" {
+		return "ip_" + ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if host == "" {
+		return ""
+	}
+	return "ip_" + host
+}
+
+// KeyByContext 按context中ctxKey对应的值（如登录中间件写入的用户ID）限流，值不存在时不限流
+func KeyByContext(ctxKey interface{}) KeyFunc {
+	return func(r *http.Request) string {
+		val := r.Context().Value(ctxKey)
+		if val == nil {
+			return ""
+		}
+		return fmt.Sprintf("user_%v", val)
+	}
+}
+
+// Middleware 限流中间件，被限流时返回429及标准的错误JSON
+// Requests are let through if the limiter fails, so a redis outage doesn't take the
+// service down.
+func Middleware(limiter Limiter, keyFn KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFn(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				logger.AddFile().WithFields(log.Fields{
+					"key":   key,
+					"error": err,
+				}).Warn("failed to check rate limit")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+				web.RespError(w, r, http.StatusTooManyRequests, res.Err())
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
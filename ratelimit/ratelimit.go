@@ -0,0 +1,251 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/log"
+	rediscm "github.com/chenjie4255/tools/redis"
+	"github.com/gomodule/redigo/redis"
+)
+
+var logger *log.Logger
+
+func init() {
+	logger = log.NewLoggerWithSentry("ratelimit")
+}
+
+var errNoPool = errors.New("rate limiter requires a redis backed DB")
+
+// Result 一次限流检查的结果
+type Result struct {
+	Allowed bool
+	// Limit 窗口内允许的请求数，令牌桶为桶容量
+	Limit int
+	// Remaining 剩余的配额
+	Remaining int
+	// RetryAfter 被限流时距离可以重试的时间，放行时为0
+	RetryAfter time.Duration
+}
+
+// Err 被限流时返回带errcode.RateLimit标签的错误，放行时返回nil
+func (r *Result) Err() error {
+	if r.Allowed {
+		return nil
+	}
+	return errors.NewWithTag(fmt.Sprintf("too many requests, retry after %s", r.RetryAfter), errcode.RateLimit)
+}
+
+// Limiter 限流器，每个key单独计数
+// AllowN consumes n units at once, it is never allowed if n exceeds the limit.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (*Result, error)
+	AllowN(ctx context.Context, key string, n int) (*Result, error)
+}
+
+// fixedWindowScript counts requests in a window which starts by the first request
+// KEYS[1] counter, ARGV[1] limit, ARGV[2] window ms, ARGV[3] n
+// returns {allowed, remaining, retry after ms}
+const fixedWindowScript = `local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+if count + n > limit then
+	local ttl = redis.call("PTTL", KEYS[1])
+	if ttl < 0 then
+		ttl = window
+	end
+	return {0, math.max(limit - count, 0), ttl}
+end
+count = redis.call("INCRBY", KEYS[1], n)
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], window)
+end
+return {1, limit - count, 0}`
+
+// slidingWindowScript logs every request in a sorted set scored by server time in ms
+// KEYS[1] log, ARGV[1] limit, ARGV[2] window ms, ARGV[3] n, ARGV[4] unique member prefix
+// returns {allowed, remaining, retry after ms}
+const slidingWindowScript = `redis.replicate_commands()
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local count = redis.call("ZCARD", KEYS[1])
+if count + n > limit then
+	local retry = window
+	-- enough old requests have to leave the window
+	local index = count + n - limit - 1
+	local oldest = redis.call("ZRANGE", KEYS[1], index, index, "WITHSCORES")
+	if oldest[2] then
+		retry = tonumber(oldest[2]) + window - now
+	end
+	return {0, math.max(limit - count, 0), retry}
+end
+for i = 1, n do
+	redis.call("ZADD", KEYS[1], now, ARGV[4] .. ":" .. i)
+end
+redis.call("PEXPIRE", KEYS[1], window)
+return {1, limit - count - n, 0}`
+
+// tokenBucketScript refills the bucket by elapsed server time
+// KEYS[1] bucket hash, ARGV[1] tokens per second, ARGV[2] burst, ARGV[3] n
+// returns {allowed, remaining, retry after ms}
+const tokenBucketScript = `redis.replicate_commands()
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(now - ts, 0) * rate / 1000)
+local allowed = 0
+local retry = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+else
+	retry = math.ceil((n - tokens) * 1000 / rate)
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry}`
+
+var (
+	fixedWindowScr   = rediscm.RegisterScript("ratelimit.fixed_window", 1, fixedWindowScript)
+	slidingWindowScr = rediscm.RegisterScript("ratelimit.sliding_window", 1, slidingWindowScript)
+	tokenBucketScr   = rediscm.RegisterScript("ratelimit.token_bucket", 1, tokenBucketScript)
+)
+
+// runScript runs scr on db, keys are prefixed by db if it is created by rediscm.WithPrefix
+func runScript(ctx context.Context, db rediscm.DB, scr *rediscm.Script, limit int, args *rediscm.ScriptArgs) (*Result, error) {
+	if db.Pool() == nil {
+		return nil, errNoPool
+	}
+
+	values, err := redis.Int64s(scr.Run(ctx, db, args))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 3 {
+		return nil, errors.Errorf("unexpected rate limit script reply %v", values)
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+func randomValue() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+// checkWindow panics on a limit or window the scripts can not enforce, a window under
+// 1ms would be a PEXPIRE of 0 which deletes the counter
+func checkWindow(limit int, window time.Duration) {
+	if limit <= 0 {
+		panic("ratelimit: limit should be greater than zero")
+	}
+	if window < time.Millisecond {
+		panic("ratelimit: window should be at least 1ms")
+	}
+}
+
+type fixedWindow struct {
+	db     rediscm.DB
+	name   string
+	limit  int
+	window time.Duration
+}
+
+// NewFixedWindow 固定窗口限流，每个窗口内最多limit次，窗口从第一次请求开始计时
+// name distinguishes limiters sharing the same keys. It panics if limit is not above 0
+// or window is under 1ms.
+func NewFixedWindow(db rediscm.DB, name string, limit int, window time.Duration) Limiter {
+	checkWindow(limit, window)
+	return &fixedWindow{db, name, limit, window}
+}
+
+func (l *fixedWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *fixedWindow) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	args := rediscm.NewScriptArgs(fmt.Sprintf("rate_limit_fw_%s_%s", l.name, key)).
+		Int(int64(l.limit)).Millis(l.window).Int(int64(n))
+	return runScript(ctx, l.db, fixedWindowScr, l.limit, args)
+}
+
+type slidingWindow struct {
+	db     rediscm.DB
+	name   string
+	limit  int
+	window time.Duration
+}
+
+// NewSlidingWindow 滑动窗口限流，任意window时长内最多limit次
+// Every request is logged in a sorted set, so it costs memory in proportion to limit.
+// The arguments are checked as in NewFixedWindow.
+func NewSlidingWindow(db rediscm.DB, name string, limit int, window time.Duration) Limiter {
+	checkWindow(limit, window)
+	return &slidingWindow{db, name, limit, window}
+}
+
+func (l *slidingWindow) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *slidingWindow) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	args := rediscm.NewScriptArgs(fmt.Sprintf("rate_limit_sw_%s_%s", l.name, key)).
+		Int(int64(l.limit)).Millis(l.window).Int(int64(n)).String(randomValue())
+	return runScript(ctx, l.db, slidingWindowScr, l.limit, args)
+}
+
+type tokenBucket struct {
+	db    rediscm.DB
+	name  string
+	rate  float64
+	burst int
+}
+
+// NewTokenBucket 令牌桶限流，每秒补充rate个令牌，最多积累burst个
+// It panics if rate or burst is not above 0.
+func NewTokenBucket(db rediscm.DB, name string, rate float64, burst int) Limiter {
+	if !(rate > 0) {
+		panic("ratelimit: rate should be greater than zero")
+	}
+	if burst <= 0 {
+		panic("ratelimit: burst should be greater than zero")
+	}
+	return &tokenBucket{db, name, rate, burst}
+}
+
+func (l *tokenBucket) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *tokenBucket) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	args := rediscm.NewScriptArgs(fmt.Sprintf("rate_limit_tb_%s_%s", l.name, key)).
+		Float(l.rate).Int(int64(l.burst)).Int(int64(n))
+	return runScript(ctx, l.db, tokenBucketScr, l.burst, args)
+}
@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	rediscm "github.com/chenjie4255/tools/redis"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestDB(t *testing.T) rediscm.DB {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	rediscm.FlushDB(env.RedisHost, "", 3)
+	return rediscm.NewDB(env.RedisHost, "", 3)
+}
+
+func TestFixedWindow(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+	limiter := NewFixedWindow(db, "test", 3, time.Second)
+
+	for i := 0; i < 3; i++ {
+		res, err := limiter.Allow(ctx, "u1")
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 2-i, res.Remaining)
+		assert.NoError(t, res.Err())
+	}
+
+	res, err := limiter.Allow(ctx, "u1")
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= time.Second)
+	assert.True(t, errors.FindTag(res.Err(), errcode.RateLimit))
+
+	// other keys are counted separately
+	res, err = limiter.AllowN(ctx, "u2", 3)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	time.Sleep(1100 * time.Millisecond)
+	res, err = limiter.Allow(ctx, "u1")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+	limiter := NewSlidingWindow(db, "test", 3, time.Second)
+
+	res, err := limiter.AllowN(ctx, "u1", 2)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 1, res.Remaining)
+
+	time.Sleep(500 * time.Millisecond)
+	res, err = limiter.Allow(ctx, "u1")
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, err = limiter.Allow(ctx, "u1")
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	// the first two requests leave the window first
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= 500*time.Millisecond, res.RetryAfter)
+
+	time.Sleep(res.RetryAfter + 50*time.Millisecond)
+	res, err = limiter.AllowN(ctx, "u1", 2)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+}
+
+func TestTokenBucket(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+	limiter := NewTokenBucket(db, "test", 10, 5)
+
+	res, err := limiter.AllowN(ctx, "u1", 5)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 5, res.Limit)
+	assert.Equal(t, 0, res.Remaining)
+
+	res, err = limiter.AllowN(ctx, "u1", 2)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= 200*time.Millisecond, res.RetryAfter)
+
+	time.Sleep(res.RetryAfter + 50*time.Millisecond)
+	res, err = limiter.AllowN(ctx, "u1", 2)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestNoPool(t *testing.T) {
+	_, err := NewFixedWindow(rediscm.NewMemoryDB(nil), "test", 1, time.Second).Allow(context.Background(), "u1")
+	assert.Equal(t, errNoPool, err)
+}
+
+func TestInvalidLimiter(t *testing.T) {
+	db := rediscm.NewMemoryDB(nil)
+	assert.Panics(t, func() { NewFixedWindow(db, "test", 0, time.Second) })
+	assert.Panics(t, func() { NewFixedWindow(db, "test", 1, time.Microsecond) })
+	assert.Panics(t, func() { NewSlidingWindow(db, "test", -1, time.Second) })
+	assert.Panics(t, func() { NewSlidingWindow(db, "test", 1, 0) })
+	assert.Panics(t, func() { NewTokenBucket(db, "test", 0, 1) })
+	assert.Panics(t, func() { NewTokenBucket(db, "test", 1, 0) })
+	assert.NotPanics(t, func() { NewFixedWindow(db, "test", 1, time.Millisecond) })
+	assert.NotPanics(t, func() { NewTokenBucket(db, "test", 0.5, 1) })
+}
+
+type fakeLimiter struct {
+	allowed map[string]int
+}
+
+func (l *fakeLimiter) Allow(ctx context.Context, key string) (*Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *fakeLimiter) AllowN(ctx context.Context, key string, n int) (*Result, error) {
+	if l.allowed[key] < n {
+		return &Result{Limit: 1, RetryAfter: 1500 * time.Millisecond}, nil
+	}
+	l.allowed[key] -= n
+	return &Result{Allowed: true, Limit: 1, Remaining: l.allowed[key]}, nil
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter := &fakeLimiter{allowed: map[string]int{"ip_1.2.3.4": 1, "user_42": 1}}
+	handler := Middleware(limiter, KeyByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 10.0.0.1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	resp := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, float64(errcode.RateLimit), resp["code"])
+
+	type ctxKey string
+	handler = Middleware(limiter, KeyByContext(ctxKey("uid")))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// requests without a user are not limited
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "", w.Header().Get("X-RateLimit-Limit"))
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), ctxKey("uid"), 42))
+	for _, code := range []int{http.StatusNoContent, http.StatusTooManyRequests} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, code, w.Code)
+	}
+}
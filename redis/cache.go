@@ -0,0 +1,247 @@
+package redis
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/clock"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/gor"
+	"github.com/chenjie4255/tools/log"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultCacheLockTTL  = 5
+	defaultCacheLockWait = 2 * time.Second
+	cacheWaitInterval    = 50 * time.Millisecond
+)
+
+// Cache 带击穿保护的CacheGet，用法与DB.CacheGet一致
+type Cache interface {
+	CacheGet(key string, v interface{}, fn FetchFunc, expires int) error
+	CacheGetB(key string, v interface{}, fn FetchFunc, expires int) (bool, error)
+
+	// Wait 等待所有后台刷新结束
+	Wait()
+}
+
+// CacheOptions 配置Cache的击穿保护策略
+type CacheOptions struct {
+	// StaleTime 过期后仍可返回旧值的秒数，期间由一个调用方在后台刷新，0表示不返回旧值
+	StaleTime int
+
+	// EarlyExpireBeta 提前过期(XFetch)的系数，越大越容易提前刷新，0表示关闭
+	EarlyExpireBeta float64
+
+	// NotFoundTTL FetchFunc返回ResNotFound时缓存该结果的秒数，0表示不缓存
+	NotFoundTTL int
+
+	// LockTTL 跨进程刷新锁的秒数，默认5秒
+	LockTTL int
+
+	// LockWait 其他进程正在刷新时等待其写入的最长时间，默认2秒，超时后自行调用FetchFunc
+	LockWait time.Duration
+
+	// Codec is used to copy fetched values into callers' outputs, JSONCodec by default
+	Codec Codec
+
+	// Clock decides whether a value is fresh, current time by default
+	Clock clock.Clock
+}
+
+// cacheEntry is the stored form of a cached value
+type cacheEntry struct {
+	Value   interface{} `json:"v" msgpack:"v"`
+	FreshTo int64       `json:"f" msgpack:"f"` // unix timestamp until which the value is fresh
+	Delta   int64       `json:"d" msgpack:"d"` // milliseconds spent by FetchFunc
+
+	// NotFound marks a cached ResNotFound result of FetchFunc, Value is empty then
+	NotFound bool `json:"n,omitempty" msgpack:"n,omitempty"`
+}
+
+func cachedNotFoundError() error {
+	return errors.NewWithTag("cached as not found", errcode.ResNotFound)
+}
+
+// loadResult is shared by callers coalesced in one singleflight call
+type loadResult struct {
+	data   []byte
+	filled bool // another process has filled the cache, callers should read it from redis
+}
+
+type cache struct {
+	db    DB
+	opts  CacheOptions
+	group singleflight.Group
+	// refreshes are coalesced apart from loads, their results are not returned to callers
+	refreshGroup singleflight.Group
+	wg           sync.WaitGroup
+}
+
+// NewCache 基于DB生成Cache
+// Concurrent misses of one key are coalesced within the process, and a short redis
+// lock coalesces them across processes. Values written by Cache carry freshness
+// metadata, so they should not be read by DB.Get directly.
+func NewCache(db DB, opts CacheOptions) Cache {
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = defaultCacheLockTTL
+	}
+	if opts.LockWait <= 0 {
+		opts.LockWait = defaultCacheLockWait
+	}
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.NewOffsetClock(0)
+	}
+
+	return &cache{db: db, opts: opts}
+}
+
+func (c *cache) CacheGet(key string, v interface{}, fn FetchFunc, expires int) error {
+	_, err := c.CacheGetB(key, v, fn, expires)
+	return err
+}
+
+func (c *cache) CacheGetB(key string, v interface{}, fn FetchFunc, expires int) (bool, error) {
+	entry := cacheEntry{Value: v}
+	if err := c.db.Get(key, &entry); err == nil {
+		now := c.opts.Clock.GetUnix()
+		if entry.NotFound {
+			// an expired not found result has no value to serve as stale, it is a miss
+			if now < entry.FreshTo {
+				return true, cachedNotFoundError()
+			}
+		} else if now < entry.FreshTo {
+			if c.earlyExpire(now, &entry) {
+				c.refreshAsync(key, fn, expires)
+			}
+			return true, nil
+		} else if now < entry.FreshTo+int64(c.opts.StaleTime) {
+			c.refreshAsync(key, fn, expires)
+			return true, nil
+		}
+	}
+
+	ret, err, _ := c.group.Do(key, func() (interface{}, error) {
+		return c.load(key, fn, expires, true)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	result := ret.(*loadResult)
+	if result.filled {
+		entry := cacheEntry{Value: v}
+		if err := c.db.Get(key, &entry); err != nil {
+			return false, err
+		}
+		if entry.NotFound {
+			return true, cachedNotFoundError()
+		}
+		return true, nil
+	}
+
+	return false, c.opts.Codec.Unmarshal(result.data, v)
+}
+
+// earlyExpire decides whether to refresh a fresh value ahead of time, see
+// "Optimal Probabilistic Cache Stampede Prevention" (XFetch)
+func (c *cache) earlyExpire(now int64, entry *cacheEntry) bool {
+	if c.opts.EarlyExpireBeta <= 0 {
+		return false
+	}
+
+	gap := float64(entry.Delta) * c.opts.EarlyExpireBeta * -math.Log(1-rand.Float64())
+	return float64(now*1000)+gap >= float64(entry.FreshTo*1000)
+}
+
+func (c *cache) refreshAsync(key string, fn FetchFunc, expires int) {
+	c.wg.Add(1)
+	gor.RunWithRecover(func() {
+		defer c.wg.Done()
+
+		c.refreshGroup.Do(key, func() (interface{}, error) {
+			return c.load(key, fn, expires, false)
+		})
+	})
+}
+
+// load calls fn and writes the value back while holding the refresh lock of key.
+// If another process holds the lock, load waits for it to fill the cache when wait
+// is true, otherwise it gives up.
+func (c *cache) load(key string, fn FetchFunc, expires int, wait bool) (*loadResult, error) {
+	lockKey := fmt.Sprintf("cache_lock_%s", key)
+	lockID, err := c.db.GetLock(lockKey, c.opts.LockTTL)
+	if err == nil {
+		defer c.db.DelLock(lockKey, lockID)
+	} else if errors.FindTag(err, errcode.ResExisted) {
+		if !wait {
+			return nil, err
+		}
+		if c.waitFilled(key) {
+			return &loadResult{filled: true}, nil
+		}
+	}
+
+	start := time.Now()
+	data, err := fn()
+	if err != nil {
+		if c.opts.NotFoundTTL > 0 && errors.FindTag(err, errcode.ResNotFound) {
+			entry := cacheEntry{
+				FreshTo:  c.opts.Clock.GetUnix() + int64(c.opts.NotFoundTTL),
+				NotFound: true,
+			}
+			c.write(key, entry, c.opts.NotFoundTTL)
+		}
+		return nil, err
+	}
+
+	rawData, err := c.opts.Codec.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := cacheEntry{
+		Value:   data,
+		FreshTo: c.opts.Clock.GetUnix() + int64(expires),
+		Delta:   int64(time.Since(start) / time.Millisecond),
+	}
+	c.write(key, entry, expires+c.opts.StaleTime)
+
+	return &loadResult{data: rawData}, nil
+}
+
+func (c *cache) write(key string, entry cacheEntry, expires int) {
+	if err := c.db.Set(key, entry, expires); err != nil {
+		logger.AddFile().WithFields(log.Fields{
+			"key":   key,
+			"error": err,
+		}).Warn("failed to write back cache")
+	}
+}
+
+// waitFilled polls key until another process writes a fresh value into it
+func (c *cache) waitFilled(key string) bool {
+	deadline := time.Now().Add(c.opts.LockWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(cacheWaitInterval)
+
+		entry := cacheEntry{}
+		if err := c.db.Get(key, &entry); err == nil && c.opts.Clock.GetUnix() < entry.FreshTo {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *cache) Wait() {
+	c.wg.Wait()
+}
@@ -0,0 +1,224 @@
+package redis
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/clock"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/stretchr/testify/assert"
+)
+
+type cacheTestItem struct {
+	Name  string  `json:"name"`
+	Price float64 `json:"price"`
+}
+
+func countingFetch(count *int32, delay time.Duration) FetchFunc {
+	return func() (interface{}, error) {
+		n := atomic.AddInt32(count, 1)
+		time.Sleep(delay)
+		return cacheTestItem{"item", float64(n)}, nil
+	}
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	db := NewMemoryDB(nil)
+	c1 := NewCache(db, CacheOptions{})
+	c2 := NewCache(db, CacheOptions{})
+
+	var count int32
+	fn := countingFetch(&count, 200*time.Millisecond)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		c := c1
+		if i%2 == 1 {
+			c = c2
+		}
+		go func() {
+			defer wg.Done()
+			check := cacheTestItem{}
+			assert.NoError(t, c.CacheGet("k1", &check, fn, 100))
+			assert.Equal(t, cacheTestItem{"item", 1}, check)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	check := cacheTestItem{}
+	hit, err := c1.CacheGetB("k1", &check, fn, 100)
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, cacheTestItem{"item", 1}, check)
+}
+
+func TestCacheStale(t *testing.T) {
+	clk := clock.NewFixedClock(1000)
+	db := NewMemoryDB(clk)
+	c := NewCache(db, CacheOptions{StaleTime: 100, Clock: clk})
+
+	var count int32
+	fn := countingFetch(&count, 0)
+
+	check := cacheTestItem{}
+	hit, err := c.CacheGetB("k1", &check, fn, 10)
+	assert.NoError(t, err)
+	assert.False(t, hit)
+
+	// stale value is served while refreshing in background
+	clk.SetTs(1050)
+	check = cacheTestItem{}
+	hit, err = c.CacheGetB("k1", &check, fn, 10)
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	assert.Equal(t, cacheTestItem{"item", 1}, check)
+
+	c.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+
+	check = cacheTestItem{}
+	assert.NoError(t, c.CacheGet("k1", &check, fn, 10))
+	assert.Equal(t, cacheTestItem{"item", 2}, check)
+
+	// out of the stale window
+	clk.SetTs(1200)
+	check = cacheTestItem{}
+	hit, err = c.CacheGetB("k1", &check, fn, 10)
+	assert.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, cacheTestItem{"item", 3}, check)
+}
+
+func TestCacheRefreshKey(t *testing.T) {
+	clk := clock.NewFixedClock(1000)
+	db := NewMemoryDB(clk)
+	c := NewCache(db, CacheOptions{StaleTime: 100, Clock: clk})
+
+	check := cacheTestItem{}
+	assert.NoError(t, c.CacheGet("foo", &check, func() (interface{}, error) {
+		return cacheTestItem{"foo", 1}, nil
+	}, 10))
+
+	// a background refresh of foo is running
+	started := make(chan struct{})
+	release := make(chan struct{})
+	clk.SetTs(1050)
+	assert.NoError(t, c.CacheGet("foo", &check, func() (interface{}, error) {
+		close(started)
+		<-release
+		return cacheTestItem{"foo", 2}, nil
+	}, 10))
+	<-started
+
+	// a caller key looking like a refresh does not join it
+	done := make(chan error, 1)
+	other := cacheTestItem{}
+	go func() {
+		done <- c.CacheGet("refresh_foo", &other, func() (interface{}, error) {
+			return cacheTestItem{"other", 1}, nil
+		}, 10)
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.Equal(t, cacheTestItem{"other", 1}, other)
+	case <-time.After(time.Second):
+		t.Fatal("refresh_foo joined the refresh of foo")
+	}
+
+	close(release)
+	c.Wait()
+}
+
+func TestCacheEarlyExpire(t *testing.T) {
+	clk := clock.NewFixedClock(1000)
+	db := NewMemoryDB(clk)
+
+	var count int32
+	fn := countingFetch(&count, 10*time.Millisecond)
+
+	check := cacheTestItem{}
+	noEarly := NewCache(db, CacheOptions{Clock: clk})
+	assert.NoError(t, noEarly.CacheGet("k1", &check, fn, 10))
+
+	clk.SetTs(1009)
+	assert.NoError(t, noEarly.CacheGet("k1", &check, fn, 10))
+	noEarly.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+
+	early := NewCache(db, CacheOptions{Clock: clk, EarlyExpireBeta: 1e9})
+	hit, err := early.CacheGetB("k1", &check, fn, 10)
+	assert.NoError(t, err)
+	assert.True(t, hit)
+	early.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+}
+
+func TestCacheNotFound(t *testing.T) {
+	clk := clock.NewFixedClock(1000)
+	db := NewMemoryDB(clk)
+	c := NewCache(db, CacheOptions{NotFoundTTL: 30, Clock: clk})
+
+	count := 0
+	fn := func() (interface{}, error) {
+		count++
+		if count == 1 {
+			return nil, errors.NewWithTag("user not found", errcode.ResNotFound)
+		}
+		return cacheTestItem{"item", 1}, nil
+	}
+
+	check := cacheTestItem{}
+	err := c.CacheGet("k1", &check, fn, 100)
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+
+	hit, err := c.CacheGetB("k1", &check, fn, 100)
+	assert.True(t, hit)
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+	assert.Equal(t, 1, count)
+
+	ttl, err := db.TTL("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, 30, ttl)
+
+	clk.SetTs(1030)
+	hit, err = c.CacheGetB("k1", &check, fn, 100)
+	assert.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, cacheTestItem{"item", 1}, check)
+	assert.Equal(t, 2, count)
+
+	// a not found result past its fresh time is a miss even if the key is still in redis
+	cacheClk := clock.NewFixedClock(1000)
+	stale := NewCache(db, CacheOptions{NotFoundTTL: 30, StaleTime: 100, Clock: cacheClk})
+	count = 0
+	err = stale.CacheGet("k3", &check, fn, 100)
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+
+	cacheClk.SetTs(1031)
+	exists, err := db.Exists("k3")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	check = cacheTestItem{}
+	hit, err = stale.CacheGetB("k3", &check, fn, 100)
+	assert.NoError(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, cacheTestItem{"item", 1}, check)
+	assert.Equal(t, 2, count)
+
+	// other errors and disabled option cache nothing
+	noNegative := NewCache(db, CacheOptions{Clock: clk})
+	fnErr := func() (interface{}, error) {
+		return nil, errors.NewWithTag("user not found", errcode.ResNotFound)
+	}
+	assert.Error(t, noNegative.CacheGet("k2", &check, fnErr, 100))
+	exists, err = db.Exists("k2")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,819 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	clusterSlots           = 16384
+	clusterMaxRedirects    = 5
+	clusterRefreshInterval = time.Second
+)
+
+var (
+	errClusterNoNode       = errors.New("no redis cluster node is available")
+	errClusterPinned       = errors.New("cluster connection is pinned to a node for pubsub")
+	errClusterNoReply      = errors.New("no pending reply on cluster connection")
+	errClusterNotSupported = errors.New("command is not supported by cluster connection")
+)
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by redis cluster
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// keySlot returns the hash slot of key, only the {hashtag} part is hashed if there is one
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % clusterSlots
+}
+
+// parseRedirect parses MOVED and ASK errors, kind is empty for other errors
+func parseRedirect(err error) (kind string, slot int, addr string) {
+	rerr, ok := err.(redis.Error)
+	if !ok {
+		return "", 0, ""
+	}
+
+	fields := strings.Fields(string(rerr))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return "", 0, ""
+	}
+	slot, convErr := strconv.Atoi(fields[1])
+	if convErr != nil {
+		return "", 0, ""
+	}
+	return fields[0], slot, fields[2]
+}
+
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// clusterKeylessCommands can be sent to any node
+var clusterKeylessCommands = map[string]bool{
+	"PING":      true,
+	"ECHO":      true,
+	"PUBLISH":   true,
+	"TIME":      true,
+	"INFO":      true,
+	"RANDOMKEY": true,
+	"CLUSTER":   true,
+	"COMMAND":   true,
+	"CLIENT":    true,
+	"READONLY":  true,
+}
+
+// commandKey returns the first key of a command, ok is false for commands without key
+func commandKey(name string, args []interface{}) (key string, ok bool) {
+	if clusterKeylessCommands[name] || len(args) == 0 {
+		return "", false
+	}
+
+	switch name {
+	case "EVAL", "EVALSHA":
+		if len(args) < 3 {
+			return "", false
+		}
+		if numKeys, _ := strconv.Atoi(argString(args[1])); numKeys == 0 {
+			return "", false
+		}
+		return argString(args[2]), true
+	case "XREAD", "XREADGROUP":
+		for i := range args[:len(args)-1] {
+			if strings.EqualFold(argString(args[i]), "STREAMS") {
+				return argString(args[i+1]), true
+			}
+		}
+		return "", false
+	case "XGROUP", "XINFO":
+		if len(args) < 2 {
+			return "", false
+		}
+		return argString(args[1]), true
+	}
+
+	return argString(args[0]), true
+}
+
+// commandSlot returns the slot a command should be sent to, -1 means any node
+func commandSlot(name string, args []interface{}) int {
+	key, ok := commandKey(name, args)
+	if !ok {
+		return -1
+	}
+	return keySlot(key)
+}
+
+// cluster keeps the slot map of a redis cluster and a pool for every node
+type cluster struct {
+	seeds    []string
+	password string
+	opts     []PoolOption
+
+	mu          sync.RWMutex
+	slots       [clusterSlots]string
+	pools       map[string]*redis.Pool
+	refreshedAt time.Time
+}
+
+func (c *cluster) nodePool(addr string) *redis.Pool {
+	c.mu.RLock()
+	pool, ok := c.pools[addr]
+	c.mu.RUnlock()
+	if ok {
+		return pool
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool, ok = c.pools[addr]; !ok {
+		pool = NewPool(addr, c.password, 0, c.opts...)
+		c.pools[addr] = pool
+	}
+	return pool
+}
+
+// masters returns the address of every node which serves slots
+func (c *cluster) masters(ctx context.Context) ([]string, error) {
+	c.mu.RLock()
+	empty := c.refreshedAt.IsZero()
+	c.mu.RUnlock()
+	if empty {
+		if err := c.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	seen := map[string]bool{}
+	addrs := []string{}
+	for _, addr := range c.slots {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errClusterNoNode
+	}
+	return addrs, nil
+}
+
+// addrOf returns the node serving slot, slot -1 means any node
+func (c *cluster) addrOf(ctx context.Context, slot int) string {
+	c.mu.RLock()
+	empty := c.refreshedAt.IsZero()
+	c.mu.RUnlock()
+	if empty {
+		c.refresh(ctx)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if slot < 0 {
+		for _, addr := range c.slots {
+			if addr != "" {
+				return addr
+			}
+		}
+	} else if addr := c.slots[slot]; addr != "" {
+		return addr
+	}
+
+	// the node answers with MOVED if it doesn't serve the slot
+	return c.seeds[0]
+}
+
+// refresh reloads the slot map by CLUSTER SLOTS from the known nodes
+func (c *cluster) refresh(ctx context.Context) error {
+	c.mu.Lock()
+	c.refreshedAt = time.Now()
+	addrs := append([]string(nil), c.seeds...)
+	seen := map[string]bool{}
+	for _, addr := range c.slots {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	c.mu.Unlock()
+
+	var lastErr error = errClusterNoNode
+	for _, addr := range addrs {
+		slots, err := c.querySlots(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.mu.Lock()
+		c.slots = slots
+		c.mu.Unlock()
+		return nil
+	}
+
+	logger.AddFile().WithField("error", lastErr).Warn("failed to refresh redis cluster slots")
+	return lastErr
+}
+
+// refreshLater refreshes the slot map unless it is refreshed recently
+func (c *cluster) refreshLater(ctx context.Context) {
+	c.mu.RLock()
+	recently := time.Since(c.refreshedAt) < clusterRefreshInterval
+	c.mu.RUnlock()
+
+	if !recently {
+		c.refresh(ctx)
+	}
+}
+
+func (c *cluster) querySlots(ctx context.Context, addr string) ([clusterSlots]string, error) {
+	slots := [clusterSlots]string{}
+
+	conn, err := c.nodePool(addr).GetContext(ctx)
+	if err != nil {
+		return slots, err
+	}
+	defer conn.Close()
+
+	ranges, err := redis.Values(redis.DoContext(conn, ctx, "CLUSTER", "SLOTS"))
+	if err != nil {
+		return slots, err
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	for _, r := range ranges {
+		fields, err := redis.Values(r, nil)
+		if err != nil || len(fields) < 3 {
+			return slots, errors.Errorf("unexpected CLUSTER SLOTS reply %v", r)
+		}
+		start, _ := redis.Int(fields[0], nil)
+		end, _ := redis.Int(fields[1], nil)
+		node, _ := redis.Values(fields[2], nil)
+		if len(node) < 2 || start < 0 || end >= clusterSlots {
+			return slots, errors.Errorf("unexpected CLUSTER SLOTS reply %v", r)
+		}
+
+		nodeHost, _ := redis.String(node[0], nil)
+		nodePort, _ := redis.Int(node[1], nil)
+		if nodeHost == "" {
+			nodeHost = host
+		}
+		nodeAddr := net.JoinHostPort(nodeHost, strconv.Itoa(nodePort))
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = nodeAddr
+		}
+	}
+
+	return slots, nil
+}
+
+func (c *cluster) setSlot(slot int, addr string) {
+	c.mu.Lock()
+	c.slots[slot] = addr
+	c.mu.Unlock()
+}
+
+// do runs a command on the node serving slot and follows MOVED and ASK redirections
+func (c *cluster) do(ctx context.Context, slot int, cmd string, args ...interface{}) (interface{}, error) {
+	addr := c.addrOf(ctx, slot)
+	asking := false
+
+	for redirects := 0; ; redirects++ {
+		conn, err := c.nodePool(addr).GetContext(ctx)
+		if err != nil {
+			// the node may be failed over, nothing is sent yet so it is safe to retry
+			if redirects == 0 && ctx.Err() == nil {
+				c.refreshLater(ctx)
+				if next := c.addrOf(ctx, slot); next != addr {
+					addr = next
+					continue
+				}
+			}
+			return nil, err
+		}
+
+		if asking {
+			conn.Send("ASKING")
+		}
+		reply, err := redis.DoContext(conn, ctx, cmd, args...)
+		conn.Close()
+
+		kind, movedSlot, movedAddr := parseRedirect(err)
+		if kind == "" || redirects >= clusterMaxRedirects {
+			if err != nil {
+				if _, ok := err.(redis.Error); !ok && ctx.Err() == nil {
+					c.refreshLater(ctx)
+				}
+			}
+			return reply, err
+		}
+
+		addr, asking = movedAddr, kind == "ASK"
+		if kind == "MOVED" {
+			c.setSlot(movedSlot, movedAddr)
+			c.refreshLater(ctx)
+		}
+	}
+}
+
+// execTx runs a transaction on a single node, all commands must be in the same slot
+func (c *cluster) execTx(ctx context.Context, slot int, cmds []clusterCmd) (interface{}, error) {
+	addr := c.addrOf(ctx, slot)
+
+	for redirects := 0; ; redirects++ {
+		conn, err := c.nodePool(addr).GetContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		conn.Send("MULTI")
+		for _, cmd := range cmds {
+			conn.Send(cmd.name, cmd.args...)
+		}
+		reply, err := redis.DoContext(conn, ctx, "EXEC")
+		conn.Close()
+
+		kind, movedSlot, movedAddr := parseRedirect(err)
+		if kind == "" || redirects >= clusterMaxRedirects {
+			return reply, err
+		}
+
+		if kind == "MOVED" {
+			c.setSlot(movedSlot, movedAddr)
+			addr = movedAddr
+		} else {
+			// ASKING doesn't work inside MULTI, wait for the migration to finish
+			time.Sleep(10 * time.Millisecond)
+			c.refresh(ctx)
+			addr = c.addrOf(ctx, slot)
+		}
+	}
+}
+
+type clusterCmd struct {
+	name string
+	args []interface{}
+}
+
+type clusterReply struct {
+	reply interface{}
+	err   error
+}
+
+// clusterConn implements redis.Conn on top of a redis cluster, commands are routed to
+// the node serving their keys. Commands sent by Send are run when their replies are
+// received.
+type clusterConn struct {
+	c *cluster
+
+	pending []clusterCmd
+	replies []clusterReply
+
+	// transaction emulation, the queued commands are run by EXEC
+	multi  bool
+	queued []clusterCmd
+
+	// SCAN emulation, the masters are scanned one by one
+	scanNodes  []string
+	scanIndex  int
+	scanCursor string
+
+	// sub is the connection pinned by SUBSCRIBE or PSUBSCRIBE
+	sub redis.Conn
+}
+
+func (cc *clusterConn) Close() error {
+	if cc.sub != nil {
+		return cc.sub.Close()
+	}
+	return nil
+}
+
+// Err returns an error once the connection is pinned for pubsub so that the pool
+// doesn't reuse it
+func (cc *clusterConn) Err() error {
+	if cc.sub != nil {
+		return errClusterPinned
+	}
+	return nil
+}
+
+func (cc *clusterConn) Send(cmd string, args ...interface{}) error {
+	if cc.sub != nil {
+		return cc.sub.Send(cmd, args...)
+	}
+
+	name := strings.ToUpper(cmd)
+	if name == "SUBSCRIBE" || name == "PSUBSCRIBE" {
+		cc.runPending(context.Background())
+
+		conn := cc.c.nodePool(cc.c.addrOf(context.Background(), -1)).Get()
+		if err := conn.Err(); err != nil {
+			conn.Close()
+			return err
+		}
+		cc.sub = conn
+		return cc.sub.Send(cmd, args...)
+	}
+
+	cc.pending = append(cc.pending, clusterCmd{cmd, args})
+	return nil
+}
+
+func (cc *clusterConn) Flush() error {
+	if cc.sub != nil {
+		return cc.sub.Flush()
+	}
+	return nil
+}
+
+func (cc *clusterConn) runPending(ctx context.Context) {
+	for _, cmd := range cc.pending {
+		reply, err := cc.run(ctx, cmd.name, cmd.args)
+		cc.replies = append(cc.replies, clusterReply{reply, err})
+	}
+	cc.pending = nil
+}
+
+func (cc *clusterConn) Receive() (interface{}, error) {
+	return cc.ReceiveContext(context.Background())
+}
+
+func (cc *clusterConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	if len(cc.replies) == 0 {
+		cc.runPending(ctx)
+	}
+	if len(cc.replies) > 0 {
+		r := cc.replies[0]
+		cc.replies = cc.replies[1:]
+		return r.reply, r.err
+	}
+
+	if cc.sub != nil {
+		return redis.ReceiveContext(cc.sub, ctx)
+	}
+	return nil, errClusterNoReply
+}
+
+// timeoutContext follows redigo, a timeout not above 0 means no timeout
+func timeoutContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+func (cc *clusterConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	return cc.ReceiveContext(ctx)
+}
+
+func (cc *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return cc.DoContext(context.Background(), cmd, args...)
+}
+
+func (cc *clusterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	return cc.DoContext(ctx, cmd, args...)
+}
+
+func (cc *clusterConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	cc.runPending(ctx)
+	replies := cc.replies
+	cc.replies = nil
+
+	if cc.sub != nil {
+		return redis.DoContext(cc.sub, ctx, cmd, args...)
+	}
+
+	if cmd == "" {
+		values := make([]interface{}, len(replies))
+		for i, r := range replies {
+			if _, ok := r.err.(redis.Error); ok || r.err == nil {
+				values[i] = r.reply
+				if r.err != nil {
+					values[i] = r.err
+				}
+				continue
+			}
+			return nil, r.err
+		}
+		return values, nil
+	}
+
+	var firstErr error
+	for _, r := range replies {
+		if r.err != nil && firstErr == nil {
+			firstErr = r.err
+		}
+	}
+
+	reply, err := cc.run(ctx, cmd, args)
+	if firstErr != nil && err == nil {
+		err = firstErr
+	}
+	return reply, err
+}
+
+// run runs a single command
+func (cc *clusterConn) run(ctx context.Context, cmd string, args []interface{}) (interface{}, error) {
+	name := strings.ToUpper(cmd)
+
+	if cc.multi {
+		switch name {
+		case "EXEC":
+			queued := cc.queued
+			cc.multi, cc.queued = false, nil
+			return cc.exec(ctx, queued)
+		case "DISCARD":
+			cc.multi, cc.queued = false, nil
+			return "OK", nil
+		case "MULTI", "WATCH":
+			return nil, redis.Error("ERR " + name + " inside MULTI is not allowed")
+		}
+		cc.queued = append(cc.queued, clusterCmd{name, args})
+		return "QUEUED", nil
+	}
+
+	switch name {
+	case "MULTI":
+		cc.multi = true
+		return "OK", nil
+	case "EXEC", "DISCARD":
+		return nil, redis.Error("ERR " + name + " without MULTI")
+	case "WATCH", "UNWATCH", "MONITOR":
+		return nil, errClusterNotSupported
+	case "SELECT":
+		if argString(args[0]) != "0" {
+			return nil, redis.Error("ERR SELECT is not allowed in cluster mode")
+		}
+		return "OK", nil
+	case "SCAN":
+		return cc.scan(ctx, args)
+	case "KEYS", "FLUSHDB", "FLUSHALL", "SCRIPT":
+		return cc.broadcast(ctx, name, args)
+	case "MGET":
+		return cc.mget(ctx, args)
+	case "DEL", "UNLINK", "EXISTS", "TOUCH":
+		return cc.count(ctx, name, args)
+	case "MSET":
+		return cc.mset(ctx, args)
+	}
+
+	return cc.c.do(ctx, commandSlot(name, args), cmd, args...)
+}
+
+// exec runs the queued commands as a transaction per slot, the transaction is only
+// atomic when all keys are in the same slot
+func (cc *clusterConn) exec(ctx context.Context, cmds []clusterCmd) (interface{}, error) {
+	slots := []int{}
+	groups := map[int][]int{}
+	for i, cmd := range cmds {
+		slot := commandSlot(cmd.name, cmd.args)
+		if slot < 0 && len(slots) > 0 {
+			slot = slots[0]
+		}
+		if _, ok := groups[slot]; !ok {
+			slots = append(slots, slot)
+		}
+		groups[slot] = append(groups[slot], i)
+	}
+
+	values := make([]interface{}, len(cmds))
+	for _, slot := range slots {
+		group := make([]clusterCmd, 0, len(groups[slot]))
+		for _, i := range groups[slot] {
+			group = append(group, cmds[i])
+		}
+
+		res, err := redis.Values(cc.c.execTx(ctx, slot, group))
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range groups[slot] {
+			if j < len(res) {
+				values[i] = res[j]
+			}
+		}
+	}
+	return values, nil
+}
+
+func (cc *clusterConn) scan(ctx context.Context, args []interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, redis.Error("ERR wrong number of arguments for 'scan' command")
+	}
+
+	if argString(args[0]) == "0" {
+		nodes, err := cc.c.masters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cc.scanNodes, cc.scanIndex, cc.scanCursor = nodes, 0, "0"
+	}
+	if cc.scanIndex >= len(cc.scanNodes) {
+		return []interface{}{[]byte("0"), []interface{}{}}, nil
+	}
+
+	conn, err := cc.c.nodePool(cc.scanNodes[cc.scanIndex]).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	values, err := redis.Values(redis.DoContext(conn, ctx, "SCAN", append([]interface{}{cc.scanCursor}, args[1:]...)...))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 2 {
+		return nil, errors.New("unexpected SCAN reply")
+	}
+
+	cc.scanCursor, _ = redis.String(values[0], nil)
+	if cc.scanCursor == "0" {
+		cc.scanIndex++
+	}
+
+	// the cursor returned is only meaningful to this connection
+	cursor := "0"
+	if cc.scanIndex < len(cc.scanNodes) {
+		cursor = strconv.Itoa(cc.scanIndex + 1)
+	}
+	return []interface{}{[]byte(cursor), values[1]}, nil
+}
+
+// broadcast runs a command on every master, KEYS replies are joined and the first
+// reply is returned for other commands
+func (cc *clusterConn) broadcast(ctx context.Context, name string, args []interface{}) (interface{}, error) {
+	nodes, err := cc.c.masters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []interface{}{}
+	var first interface{}
+	for i, addr := range nodes {
+		conn, err := cc.c.nodePool(addr).GetContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		reply, err := redis.DoContext(conn, ctx, name, args...)
+		conn.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if name == "KEYS" {
+			values, _ := redis.Values(reply, nil)
+			keys = append(keys, values...)
+		} else if i == 0 {
+			first = reply
+		}
+	}
+
+	if name == "KEYS" {
+		return keys, nil
+	}
+	return first, nil
+}
+
+// groupKeys groups the indexes of keys by slot in order of appearance
+func groupKeys(keys []interface{}) ([]int, map[int][]int) {
+	slots := []int{}
+	groups := map[int][]int{}
+	for i, key := range keys {
+		slot := keySlot(argString(key))
+		if _, ok := groups[slot]; !ok {
+			slots = append(slots, slot)
+		}
+		groups[slot] = append(groups[slot], i)
+	}
+	return slots, groups
+}
+
+func (cc *clusterConn) mget(ctx context.Context, keys []interface{}) (interface{}, error) {
+	values := make([]interface{}, len(keys))
+	slots, groups := groupKeys(keys)
+	for _, slot := range slots {
+		args := make([]interface{}, 0, len(groups[slot]))
+		for _, i := range groups[slot] {
+			args = append(args, keys[i])
+		}
+
+		res, err := redis.Values(cc.c.do(ctx, slot, "MGET", args...))
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range groups[slot] {
+			if j < len(res) {
+				values[i] = res[j]
+			}
+		}
+	}
+	return values, nil
+}
+
+func (cc *clusterConn) count(ctx context.Context, name string, keys []interface{}) (interface{}, error) {
+	var total int64
+	slots, groups := groupKeys(keys)
+	for _, slot := range slots {
+		args := make([]interface{}, 0, len(groups[slot]))
+		for _, i := range groups[slot] {
+			args = append(args, keys[i])
+		}
+
+		n, err := redis.Int64(cc.c.do(ctx, slot, name, args...))
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func (cc *clusterConn) mset(ctx context.Context, args []interface{}) (interface{}, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, redis.Error("ERR wrong number of arguments for 'mset' command")
+	}
+
+	keys := make([]interface{}, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		keys = append(keys, args[i])
+	}
+
+	slots, groups := groupKeys(keys)
+	for _, slot := range slots {
+		pairs := make([]interface{}, 0, len(groups[slot])*2)
+		for _, i := range groups[slot] {
+			pairs = append(pairs, args[i*2], args[i*2+1])
+		}
+
+		if _, err := cc.c.do(ctx, slot, "MSET", pairs...); err != nil {
+			return nil, err
+		}
+	}
+	return "OK", nil
+}
+
+// NewClusterPool 生成连接redis cluster的redis.Pool，addrs为部分节点地址
+// Keys are routed by hash slot and MOVED/ASK redirections are followed, commands
+// with several keys are split by slot and MULTI/EXEC is only atomic within a slot.
+// opts apply to the returned pool and to the pool of every node.
+func NewClusterPool(addrs []string, password string, opts ...PoolOption) *redis.Pool {
+	c := &cluster{
+		seeds:    append([]string(nil), addrs...),
+		password: password,
+		opts:     opts,
+		pools:    map[string]*redis.Pool{},
+	}
+
+	return newPoolConfig(password, 0, opts).newPool(func(ctx context.Context) (redis.Conn, error) {
+		if len(c.seeds) == 0 {
+			return nil, errClusterNoNode
+		}
+		return &clusterConn{c: c}, nil
+	})
+}
+
+// NewClusterDB 生成连接redis cluster的DB
+func NewClusterDB(addrs []string, password string, opts ...PoolOption) DB {
+	return NewDBFromPool(NewClusterPool(addrs, password, opts...))
+}
@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySlot(t *testing.T) {
+	assert.Equal(t, 12182, keySlot("foo"))
+	assert.Equal(t, 12739, keySlot("123456789"))
+	assert.Equal(t, keySlot("user1"), keySlot("{user1}.name"))
+	assert.Equal(t, keySlot("{user1}.name"), keySlot("{user1}.age"))
+	// empty hashtag is not a hashtag
+	assert.Equal(t, int(crc16("{}foo"))%clusterSlots, keySlot("{}foo"))
+}
+
+func TestCommandSlot(t *testing.T) {
+	assert.Equal(t, -1, commandSlot("PING", nil))
+	assert.Equal(t, -1, commandSlot("PUBLISH", []interface{}{"foo", "data"}))
+	assert.Equal(t, keySlot("foo"), commandSlot("GET", []interface{}{"foo"}))
+	assert.Equal(t, keySlot("foo"), commandSlot("EVALSHA", []interface{}{"sha", 1, []byte("foo"), "arg"}))
+	assert.Equal(t, -1, commandSlot("EVAL", []interface{}{"script", 0}))
+	assert.Equal(t, keySlot("s1"), commandSlot("XREADGROUP", []interface{}{"GROUP", "g", "c", "COUNT", 1, "STREAMS", "s1", ">"}))
+	assert.Equal(t, keySlot("s1"), commandSlot("XGROUP", []interface{}{"CREATE", "s1", "g", "$"}))
+}
+
+func TestParseRedirect(t *testing.T) {
+	kind, slot, addr := parseRedirect(redis.Error("MOVED 3999 127.0.0.1:6381"))
+	assert.Equal(t, "MOVED", kind)
+	assert.Equal(t, 3999, slot)
+	assert.Equal(t, "127.0.0.1:6381", addr)
+
+	kind, slot, addr = parseRedirect(redis.Error("ASK 12182 10.0.0.2:7000"))
+	assert.Equal(t, "ASK", kind)
+	assert.Equal(t, 12182, slot)
+	assert.Equal(t, "10.0.0.2:7000", addr)
+
+	kind, _, _ = parseRedirect(redis.Error("ERR unknown command"))
+	assert.Equal(t, "", kind)
+	kind, _, _ = parseRedirect(nil)
+	assert.Equal(t, "", kind)
+}
+
+func TestTimeoutContext(t *testing.T) {
+	// 0 means no timeout as in redigo
+	ctx, cancel := timeoutContext(0)
+	defer cancel()
+	assert.NoError(t, ctx.Err())
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+
+	ctx, cancel = timeoutContext(time.Second)
+	defer cancel()
+	assert.NoError(t, ctx.Err())
+	_, ok = ctx.Deadline()
+	assert.True(t, ok)
+}
+
+func TestClusterPoolOptions(t *testing.T) {
+	pool := NewClusterPool([]string{"127.0.0.1:7000"}, "", WithMaxIdle(3), WithMaxActive(5, true), WithIdleTimeout(time.Minute))
+	assert.Equal(t, 3, pool.MaxIdle)
+	assert.Equal(t, 5, pool.MaxActive)
+	assert.True(t, pool.Wait)
+	assert.Equal(t, time.Minute, pool.IdleTimeout)
+	assert.NotNil(t, pool.TestOnBorrow)
+}
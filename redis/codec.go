@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/chenjie4255/errors"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec 负责DB中value的编解码
+// Compare-and-swap operations (DelKeyForValue, ReplaceValue, CmpAndSet, DelLock...) compare
+// the encoded bytes, so a codec should encode equal values into equal bytes.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec 默认的编码方式
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec encodes values with msgpack, keys of map[string]string/bool/interface{} are sorted to keep the output stable
+	MsgpackCodec Codec = msgpackCodec{}
+	// GobCodec encodes values with encoding/gob, maps are not encoded in a stable order
+	GobCodec Codec = gobCodec{}
+	// RawCodec 直接存取[]byte和string，不做任何编码
+	RawCodec Codec = rawCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, &v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case []byte:
+		return val, nil
+	case string:
+		return []byte(val), nil
+	case *[]byte:
+		return *val, nil
+	case *string:
+		return []byte(*val), nil
+	}
+
+	return nil, errors.Errorf("raw codec does not support %T", v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch val := v.(type) {
+	case *[]byte:
+		*val = append([]byte(nil), data...)
+		return nil
+	case *string:
+		*val = string(data)
+		return nil
+	}
+
+	return errors.Errorf("raw codec does not support %T", v)
+}
@@ -0,0 +1,86 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	type Item struct {
+		Name  string  `json:"name" msgpack:"name"`
+		Price float64 `json:"price" msgpack:"price"`
+	}
+
+	item := Item{"item", 1.99}
+	for name, codec := range map[string]Codec{"json": JSONCodec, "msgpack": MsgpackCodec, "gob": GobCodec} {
+		data, err := codec.Marshal(item)
+		assert.NoError(t, err, name)
+
+		check := Item{}
+		assert.NoError(t, codec.Unmarshal(data, &check), name)
+		assert.Equal(t, item, check, name)
+
+		m := map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4}
+		if codec != GobCodec {
+			data1, _ := codec.Marshal(m)
+			data2, _ := codec.Marshal(m)
+			assert.Equal(t, data1, data2, name)
+		}
+	}
+}
+
+func TestRawCodec(t *testing.T) {
+	data, err := RawCodec.Marshal("abc")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("abc"), data)
+
+	data, err = RawCodec.Marshal([]byte{0, 1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2}, data)
+
+	_, err = RawCodec.Marshal(100)
+	assert.Error(t, err)
+
+	str := ""
+	assert.NoError(t, RawCodec.Unmarshal([]byte("abc"), &str))
+	assert.Equal(t, "abc", str)
+
+	var buf []byte
+	assert.NoError(t, RawCodec.Unmarshal([]byte{0, 1, 2}, &buf))
+	assert.Equal(t, []byte{0, 1, 2}, buf)
+
+	assert.Error(t, RawCodec.Unmarshal([]byte("1"), new(int)))
+}
+
+func TestDBCodec(t *testing.T) {
+	type Item struct {
+		Name  string
+		Price float64
+	}
+
+	for name, codec := range map[string]Codec{"msgpack": MsgpackCodec, "gob": GobCodec} {
+		db := NewMemoryDBWithCodec(nil, codec)
+
+		item := Item{"item", 1.99}
+		assert.NoError(t, db.Set("k1", item, 0), name)
+
+		check := Item{}
+		assert.NoError(t, db.Get("k1", &check), name)
+		assert.Equal(t, item, check, name)
+
+		newItem := Item{"item", 2.99}
+		assert.Error(t, db.ReplaceValue("k1", newItem, item), name)
+		assert.NoError(t, db.ReplaceValue("k1", item, newItem), name)
+		assert.NoError(t, db.DelKeyForValue("k1", newItem), name)
+
+		lockID, err := db.GetLock("lock", 10)
+		assert.NoError(t, err, name)
+		assert.NoError(t, db.SetLockTTL("lock", lockID, 10), name)
+		assert.NoError(t, db.DelLock("lock", lockID), name)
+	}
+
+	db := NewMemoryDBWithCodec(nil, RawCodec)
+	assert.NoError(t, db.Set("k1", "v1", 0))
+	assert.NoError(t, db.DelKeyForBytes("k1", []byte("v1")))
+}
@@ -0,0 +1,729 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// The DB methods of db are thin adapters of the ContextDB ones, bound to context.Background()
+
+func (d *db) Get(key string, v interface{}) error {
+	return d.GetCtx(context.Background(), key, v)
+}
+
+func (d *db) Set(key string, value interface{}, expires int) error {
+	return d.SetCtx(context.Background(), key, value, expires)
+}
+
+func (d *db) SetNotExists(key string, value interface{}, expires int) error {
+	return d.SetNotExistsCtx(context.Background(), key, value, expires)
+}
+
+func (d *db) Del(key string) error {
+	return d.DelCtx(context.Background(), key)
+}
+
+func (d *db) MGet(keys []string, outputs []interface{}) ([]bool, error) {
+	return d.MGetCtx(context.Background(), keys, outputs)
+}
+
+func (d *db) MSet(values map[string]interface{}, expires int) error {
+	return d.MSetCtx(context.Background(), values, expires)
+}
+
+func (d *db) DelByKeys(keyPattern string) error {
+	return d.DelByKeysCtx(context.Background(), keyPattern)
+}
+
+func (d *db) DelKeysByScan(keyPattern string) error {
+	return d.DelKeysByScanCtx(context.Background(), keyPattern)
+}
+
+func (d *db) DelKeysByScanCount(keyPattern string, count int) error {
+	return d.DelKeysByScanCountCtx(context.Background(), keyPattern, count)
+}
+
+func (d *db) ScanKeys(pattern string, count int, fn func(keys []string) error) error {
+	return d.ScanKeysCtx(context.Background(), pattern, count, fn)
+}
+
+func (d *db) SScan(key, pattern string, count int, fn func(members [][]byte) error) error {
+	return d.SScanCtx(context.Background(), key, pattern, count, fn)
+}
+
+func (d *db) ZScan(key, pattern string, count int, fn func(members []ZMember) error) error {
+	return d.ZScanCtx(context.Background(), key, pattern, count, fn)
+}
+
+func (d *db) HScan(key, pattern string, count int, fn func(fields map[string][]byte) error) error {
+	return d.HScanCtx(context.Background(), key, pattern, count, fn)
+}
+
+func (d *db) DelKeys(keys []string) error {
+	return d.DelKeysCtx(context.Background(), keys)
+}
+
+func (d *db) DelKeyForValue(key string, value interface{}) error {
+	return d.DelKeyForValueCtx(context.Background(), key, value)
+}
+
+func (d *db) DelKeyForBytes(key string, value []byte) error {
+	return d.DelKeyForBytesCtx(context.Background(), key, value)
+}
+
+func (d *db) ReplaceValue(key string, oldValue, newValue interface{}) error {
+	return d.ReplaceValueCtx(context.Background(), key, oldValue, newValue)
+}
+
+func (d *db) CmpAndSet(cmpKey string, cmpValue interface{}, setKey string, setValue interface{}) error {
+	return d.CmpAndSetCtx(context.Background(), cmpKey, cmpValue, setKey, setValue)
+}
+
+func (d *db) CmpGTDecr(cmpKey string, greatThan int64) (int64, error) {
+	return d.CmpGTDecrCtx(context.Background(), cmpKey, greatThan)
+}
+
+func (d *db) GetLock(key string, seconds int) (string, error) {
+	return d.GetLockCtx(context.Background(), key, seconds)
+}
+
+func (d *db) DelLock(key string, lockID string) error {
+	return d.DelLockCtx(context.Background(), key, lockID)
+}
+
+func (d *db) SetLockTTL(key string, lockID string, seconds int) error {
+	return d.SetLockTTLCtx(context.Background(), key, lockID, seconds)
+}
+
+func (d *db) IncrByUint64(key string, step uint64) (uint64, error) {
+	return d.IncrByUint64Ctx(context.Background(), key, step)
+}
+
+func (d *db) IncrToUint64(key string, val uint64) (uint64, error) {
+	return d.IncrToUint64Ctx(context.Background(), key, val)
+}
+
+func (d *db) DecrByUint64(key string, step uint64) (uint64, error) {
+	return d.DecrByUint64Ctx(context.Background(), key, step)
+}
+
+func (d *db) Exists(key string) (bool, error) {
+	return d.ExistsCtx(context.Background(), key)
+}
+
+func (d *db) CacheGet(key string, v interface{}, fn FetchFunc, expires int) error {
+	return d.CacheGetCtx(context.Background(), key, v, fn, expires)
+}
+
+func (d *db) CacheGetB(key string, v interface{}, fn FetchFunc, expires int) (bool, error) {
+	return d.CacheGetBCtx(context.Background(), key, v, fn, expires)
+}
+
+func (d *db) AddSortSetStr(key string, value string, sortKey int64) error {
+	return d.AddSortSetStrCtx(context.Background(), key, value, sortKey)
+}
+
+func (d *db) GetSortSetCount(key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	return d.GetSortSetCountCtx(context.Background(), key, sortKeyFrom, sortKeyTo)
+}
+
+func (d *db) GetSortSetRangeStr(key string, sortKeyFrom, sortKeyTo int64) ([]string, error) {
+	return d.GetSortSetRangeStrCtx(context.Background(), key, sortKeyFrom, sortKeyTo)
+}
+
+func (d *db) RemoveSortSet(key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	return d.RemoveSortSetCtx(context.Background(), key, sortKeyFrom, sortKeyTo)
+}
+
+func (d *db) ZAdd(key string, members ...ZMember) (int, error) {
+	return d.ZAddCtx(context.Background(), key, members...)
+}
+
+func (d *db) ZIncrBy(key, member string, step float64) (float64, error) {
+	return d.ZIncrByCtx(context.Background(), key, member, step)
+}
+
+func (d *db) ZScore(key, member string) (float64, error) {
+	return d.ZScoreCtx(context.Background(), key, member)
+}
+
+func (d *db) ZRank(key, member string) (int, error) {
+	return d.ZRankCtx(context.Background(), key, member)
+}
+
+func (d *db) ZRevRank(key, member string) (int, error) {
+	return d.ZRevRankCtx(context.Background(), key, member)
+}
+
+func (d *db) ZRangeWithScores(key string, start, stop int) ([]ZMember, error) {
+	return d.ZRangeWithScoresCtx(context.Background(), key, start, stop)
+}
+
+func (d *db) ZRevRangeWithScores(key string, start, stop int) ([]ZMember, error) {
+	return d.ZRevRangeWithScoresCtx(context.Background(), key, start, stop)
+}
+
+func (d *db) ZRangeByScoreWithScores(key string, min, max float64, offset, count int) ([]ZMember, error) {
+	return d.ZRangeByScoreWithScoresCtx(context.Background(), key, min, max, offset, count)
+}
+
+func (d *db) ZRevRangeByScoreWithScores(key string, max, min float64, offset, count int) ([]ZMember, error) {
+	return d.ZRevRangeByScoreWithScoresCtx(context.Background(), key, max, min, offset, count)
+}
+
+func (d *db) ZRem(key string, members ...string) (int, error) {
+	return d.ZRemCtx(context.Background(), key, members...)
+}
+
+func (d *db) PushStringList(key string, value string, expires int) error {
+	return d.PushStringListCtx(context.Background(), key, value, expires)
+}
+
+func (d *db) GetStringList(key string) ([]string, error) {
+	return d.GetStringListCtx(context.Background(), key)
+}
+
+func (d *db) HSet(key, field string, value interface{}, expires int) error {
+	return d.HSetCtx(context.Background(), key, field, value, expires)
+}
+
+func (d *db) HMSet(key string, values interface{}, expires int) error {
+	return d.HMSetCtx(context.Background(), key, values, expires)
+}
+
+func (d *db) HGet(key, field string, v interface{}) error {
+	return d.HGetCtx(context.Background(), key, field, v)
+}
+
+func (d *db) HMGet(key string, fields []string, outputs []interface{}) ([]bool, error) {
+	return d.HMGetCtx(context.Background(), key, fields, outputs)
+}
+
+func (d *db) HGetAll(key string, v interface{}) error {
+	return d.HGetAllCtx(context.Background(), key, v)
+}
+
+func (d *db) HDel(key string, fields ...string) (int, error) {
+	return d.HDelCtx(context.Background(), key, fields...)
+}
+
+func (d *db) HIncrBy(key, field string, step int64) (int64, error) {
+	return d.HIncrByCtx(context.Background(), key, field, step)
+}
+
+func (d *db) HExists(key, field string) (bool, error) {
+	return d.HExistsCtx(context.Background(), key, field)
+}
+
+func (d *db) TTL(key string) (int, error) {
+	return d.TTLCtx(context.Background(), key)
+}
+
+func (d *db) Time() (int64, error) {
+	return d.TimeCtx(context.Background())
+}
+
+func (d *db) SAdd(key string, values ...[]byte) error {
+	return d.SAddCtx(context.Background(), key, values...)
+}
+
+func (d *db) SRandMember(key string, count int) ([][]byte, error) {
+	return d.SRandMemberCtx(context.Background(), key, count)
+}
+
+func (d *db) SCard(key string) (int, error) {
+	return d.SCardCtx(context.Background(), key)
+}
+
+func (d *db) Subscribe(channels []string, done chan bool, recv func(name string, data []byte)) error {
+	return d.SubscribeCtx(context.Background(), channels, done, recv)
+}
+
+func (d *db) Publish(channel string, data []byte) (int, error) {
+	return d.PublishCtx(context.Background(), channel, data)
+}
+
+// AdaptContextDB 将DB适配为ContextDB
+// DB implementations without native context support (e.g. NewMemoryDB) only check
+// ctx before each call; a DB which already implements ContextDB is returned as it is.
+func AdaptContextDB(d DB) ContextDB {
+	if cdb, ok := d.(ContextDB); ok {
+		return cdb
+	}
+	return &contextAdapter{d}
+}
+
+type contextAdapter struct {
+	d DB
+}
+
+func (a *contextAdapter) GetCtx(ctx context.Context, key string, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.Get(key, v)
+}
+
+func (a *contextAdapter) SetCtx(ctx context.Context, key string, value interface{}, expires int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.Set(key, value, expires)
+}
+
+func (a *contextAdapter) SetNotExistsCtx(ctx context.Context, key string, value interface{}, expires int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.SetNotExists(key, value, expires)
+}
+
+func (a *contextAdapter) DelCtx(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.Del(key)
+}
+
+func (a *contextAdapter) MGetCtx(ctx context.Context, keys []string, outputs []interface{}) ([]bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.MGet(keys, outputs)
+}
+
+func (a *contextAdapter) MSetCtx(ctx context.Context, values map[string]interface{}, expires int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.MSet(values, expires)
+}
+
+func (a *contextAdapter) DelByKeysCtx(ctx context.Context, keyPattern string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.DelByKeys(keyPattern)
+}
+
+func (a *contextAdapter) DelKeysByScanCtx(ctx context.Context, keyPattern string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.DelKeysByScan(keyPattern)
+}
+
+func (a *contextAdapter) DelKeysByScanCountCtx(ctx context.Context, keyPattern string, count int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.DelKeysByScanCount(keyPattern, count)
+}
+
+func (a *contextAdapter) ScanKeysCtx(ctx context.Context, pattern string, count int, fn func(keys []string) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.ScanKeys(pattern, count, func(keys []string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(keys)
+	})
+}
+
+func (a *contextAdapter) SScanCtx(ctx context.Context, key, pattern string, count int, fn func(members [][]byte) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.SScan(key, pattern, count, func(members [][]byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(members)
+	})
+}
+
+func (a *contextAdapter) ZScanCtx(ctx context.Context, key, pattern string, count int, fn func(members []ZMember) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.ZScan(key, pattern, count, func(members []ZMember) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(members)
+	})
+}
+
+func (a *contextAdapter) HScanCtx(ctx context.Context, key, pattern string, count int, fn func(fields map[string][]byte) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.HScan(key, pattern, count, func(fields map[string][]byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(fields)
+	})
+}
+
+func (a *contextAdapter) DelKeysCtx(ctx context.Context, keys []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.DelKeys(keys)
+}
+
+func (a *contextAdapter) DelKeyForValueCtx(ctx context.Context, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.DelKeyForValue(key, value)
+}
+
+func (a *contextAdapter) DelKeyForBytesCtx(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.DelKeyForBytes(key, value)
+}
+
+func (a *contextAdapter) ReplaceValueCtx(ctx context.Context, key string, oldValue, newValue interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.ReplaceValue(key, oldValue, newValue)
+}
+
+func (a *contextAdapter) CmpAndSetCtx(ctx context.Context, cmpKey string, cmpValue interface{}, setKey string, setValue interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.CmpAndSet(cmpKey, cmpValue, setKey, setValue)
+}
+
+func (a *contextAdapter) CmpGTDecrCtx(ctx context.Context, cmpKey string, greatThan int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.CmpGTDecr(cmpKey, greatThan)
+}
+
+func (a *contextAdapter) GetLockCtx(ctx context.Context, key string, seconds int) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return a.d.GetLock(key, seconds)
+}
+
+func (a *contextAdapter) DelLockCtx(ctx context.Context, key string, lockID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.DelLock(key, lockID)
+}
+
+func (a *contextAdapter) SetLockTTLCtx(ctx context.Context, key string, lockID string, ttl int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.SetLockTTL(key, lockID, ttl)
+}
+
+func (a *contextAdapter) IncrByUint64Ctx(ctx context.Context, key string, step uint64) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.IncrByUint64(key, step)
+}
+
+func (a *contextAdapter) IncrToUint64Ctx(ctx context.Context, key string, val uint64) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.IncrToUint64(key, val)
+}
+
+func (a *contextAdapter) DecrByUint64Ctx(ctx context.Context, key string, step uint64) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.DecrByUint64(key, step)
+}
+
+func (a *contextAdapter) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.d.Exists(key)
+}
+
+func (a *contextAdapter) CacheGetCtx(ctx context.Context, key string, v interface{}, fn FetchFunc, expires int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.CacheGet(key, v, fn, expires)
+}
+
+func (a *contextAdapter) CacheGetBCtx(ctx context.Context, key string, v interface{}, fn FetchFunc, expires int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.d.CacheGetB(key, v, fn, expires)
+}
+
+func (a *contextAdapter) AddSortSetStrCtx(ctx context.Context, key string, value string, sortKey int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.AddSortSetStr(key, value, sortKey)
+}
+
+func (a *contextAdapter) GetSortSetCountCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.GetSortSetCount(key, sortKeyFrom, sortKeyTo)
+}
+
+func (a *contextAdapter) GetSortSetRangeStrCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.GetSortSetRangeStr(key, sortKeyFrom, sortKeyTo)
+}
+
+func (a *contextAdapter) RemoveSortSetCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.RemoveSortSet(key, sortKeyFrom, sortKeyTo)
+}
+
+func (a *contextAdapter) ZAddCtx(ctx context.Context, key string, members ...ZMember) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.ZAdd(key, members...)
+}
+
+func (a *contextAdapter) ZIncrByCtx(ctx context.Context, key, member string, step float64) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.ZIncrBy(key, member, step)
+}
+
+func (a *contextAdapter) ZScoreCtx(ctx context.Context, key, member string) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.ZScore(key, member)
+}
+
+func (a *contextAdapter) ZRankCtx(ctx context.Context, key, member string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.ZRank(key, member)
+}
+
+func (a *contextAdapter) ZRevRankCtx(ctx context.Context, key, member string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.ZRevRank(key, member)
+}
+
+func (a *contextAdapter) ZRangeWithScoresCtx(ctx context.Context, key string, start, stop int) ([]ZMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.ZRangeWithScores(key, start, stop)
+}
+
+func (a *contextAdapter) ZRevRangeWithScoresCtx(ctx context.Context, key string, start, stop int) ([]ZMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.ZRevRangeWithScores(key, start, stop)
+}
+
+func (a *contextAdapter) ZRangeByScoreWithScoresCtx(ctx context.Context, key string, min, max float64, offset, count int) ([]ZMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.ZRangeByScoreWithScores(key, min, max, offset, count)
+}
+
+func (a *contextAdapter) ZRevRangeByScoreWithScoresCtx(ctx context.Context, key string, max, min float64, offset, count int) ([]ZMember, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.ZRevRangeByScoreWithScores(key, max, min, offset, count)
+}
+
+func (a *contextAdapter) ZRemCtx(ctx context.Context, key string, members ...string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.ZRem(key, members...)
+}
+
+func (a *contextAdapter) PushStringListCtx(ctx context.Context, key string, value string, expires int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.PushStringList(key, value, expires)
+}
+
+func (a *contextAdapter) GetStringListCtx(ctx context.Context, key string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.GetStringList(key)
+}
+
+func (a *contextAdapter) HSetCtx(ctx context.Context, key, field string, value interface{}, expires int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.HSet(key, field, value, expires)
+}
+
+func (a *contextAdapter) HMSetCtx(ctx context.Context, key string, values interface{}, expires int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.HMSet(key, values, expires)
+}
+
+func (a *contextAdapter) HGetCtx(ctx context.Context, key, field string, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.HGet(key, field, v)
+}
+
+func (a *contextAdapter) HMGetCtx(ctx context.Context, key string, fields []string, outputs []interface{}) ([]bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.HMGet(key, fields, outputs)
+}
+
+func (a *contextAdapter) HGetAllCtx(ctx context.Context, key string, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.HGetAll(key, v)
+}
+
+func (a *contextAdapter) HDelCtx(ctx context.Context, key string, fields ...string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.HDel(key, fields...)
+}
+
+func (a *contextAdapter) HIncrByCtx(ctx context.Context, key, field string, step int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.HIncrBy(key, field, step)
+}
+
+func (a *contextAdapter) HExistsCtx(ctx context.Context, key, field string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.d.HExists(key, field)
+}
+
+func (a *contextAdapter) TTLCtx(ctx context.Context, key string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.TTL(key)
+}
+
+func (a *contextAdapter) TimeCtx(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.Time()
+}
+
+func (a *contextAdapter) SAddCtx(ctx context.Context, key string, values ...[]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.d.SAdd(key, values...)
+}
+
+func (a *contextAdapter) SRandMemberCtx(ctx context.Context, key string, count int) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.d.SRandMember(key, count)
+}
+
+func (a *contextAdapter) SCardCtx(ctx context.Context, key string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.SCard(key)
+}
+
+// ctxSubscriber is implemented by DBs which can end a subscription by ctx, like memoryDB
+type ctxSubscriber interface {
+	SubscribeCtx(ctx context.Context, channels []string, done chan bool, recv func(name string, data []byte)) error
+}
+
+// SubscribeCtx returns once ctx is done, messages arriving after that are dropped.
+// Subscribe of a DB without SubscribeCtx keeps running in the background then.
+func (a *contextAdapter) SubscribeCtx(ctx context.Context, channels []string, done chan bool, recv func(name string, data []byte)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s, ok := a.d.(ctxSubscriber); ok {
+		return s.SubscribeCtx(ctx, channels, done, recv)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- a.d.Subscribe(channels, done, func(name string, data []byte) {
+			if ctx.Err() == nil {
+				recv(name, data)
+			}
+		})
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errChan:
+		return err
+	}
+}
+
+func (a *contextAdapter) PublishCtx(ctx context.Context, channel string, data []byte) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return a.d.Publish(channel, data)
+}
+
+func (a *contextAdapter) NewSubscriber(recv func(channel string, data []byte), opts SubscriberOptions) Subscriber {
+	return a.d.NewSubscriber(recv, opts)
+}
+
+func (a *contextAdapter) Pipeline() Pipeline {
+	return a.d.Pipeline()
+}
+
+func (a *contextAdapter) Pool() *redis.Pool {
+	return a.d.Pool()
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextDBDeadline(t *testing.T) {
+	// a server which accepts connections but never replies
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				io.Copy(ioutil.Discard, conn)
+			}(conn)
+		}
+	}()
+
+	db := NewContextDB(l.Addr().String(), "", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	check := 0
+	err = db.GetCtx(ctx, "k1", &check)
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 2*time.Second)
+}
+
+func TestAdaptContextDB(t *testing.T) {
+	cdb := AdaptContextDB(NewMemoryDB(nil))
+
+	assert.NoError(t, cdb.SetCtx(context.Background(), "k1", 1, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	check := 0
+	err := cdb.GetCtx(ctx, "k1", &check)
+	assert.Equal(t, context.Canceled, err)
+
+	err = cdb.SubscribeCtx(ctx, []string{"c1"}, make(chan bool, 1), func(name string, data []byte) {})
+	assert.Equal(t, context.Canceled, err)
+	n, err := cdb.PublishCtx(context.Background(), "c1", []byte("m"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	err = cdb.GetCtx(context.Background(), "k1", &check)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, check)
+
+	_, ok := AdaptContextDB(NewDB("127.0.0.1:6379", "", 0)).(*db)
+	assert.True(t, ok)
+}
+
+func TestMemorySubscribeCtx(t *testing.T) {
+	m := NewMemoryDB(nil)
+	cdb := AdaptContextDB(m)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool, 1)
+	received := make(chan string, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- cdb.SubscribeCtx(ctx, []string{"c1"}, done, func(name string, data []byte) {
+			received <- string(data)
+		})
+	}()
+	<-done
+
+	_, err := m.Publish("c1", []byte("m1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "m1", <-received)
+
+	// the subscription is removed once ctx is done
+	cancel()
+	select {
+	case err := <-errChan:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("SubscribeCtx does not return")
+	}
+	n, err := m.Publish("c1", []byte("m2"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
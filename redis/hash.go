@@ -0,0 +1,215 @@
+package redis
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/gomodule/redigo/redis"
+)
+
+// hash fields of a struct are named by the `redis` tag, or the field name when the tag
+// is absent; fields tagged `redis:"-"` and unexported fields are ignored.
+
+type hashField struct {
+	name  string
+	index int
+}
+
+func structHashFields(t reflect.Type) []hashField {
+	ret := []hashField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Tag.Get("redis")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		ret = append(ret, hashField{name, i})
+	}
+	return ret
+}
+
+// encodeHash encodes a struct, a pointer to struct or a map with string keys into hash fields
+func encodeHash(codec Codec, values interface{}) (map[string][]byte, error) {
+	rv := reflect.ValueOf(values)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+
+	ret := make(map[string][]byte)
+	switch rv.Kind() {
+	case reflect.Struct:
+		for _, f := range structHashFields(rv.Type()) {
+			data, err := codec.Marshal(rv.Field(f.index).Interface())
+			if err != nil {
+				return nil, err
+			}
+			ret[f.name] = data
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, errors.Errorf("hash values should be keyed by string, got %s", rv.Type())
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			data, err := codec.Marshal(iter.Value().Interface())
+			if err != nil {
+				return nil, err
+			}
+			ret[iter.Key().String()] = data
+		}
+	default:
+		return nil, errors.Errorf("unsupported hash values %T", values)
+	}
+
+	return ret, nil
+}
+
+// decodeHash decodes hash fields into a pointer to struct, fields missing in the hash are untouched
+func decodeHash(codec Codec, fields map[string][]byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("hash can only be decoded into a pointer to struct, got %T", v)
+	}
+	rv = rv.Elem()
+
+	for _, f := range structHashFields(rv.Type()) {
+		data, found := fields[f.name]
+		if !found {
+			continue
+		}
+		if err := codec.Unmarshal(data, rv.Field(f.index).Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hashNotFoundError() error {
+	return errors.NewWithTag("hash field doesn't exists", errcode.ResNotFound)
+}
+
+// hsetExpireScript writes the field and value pairs from ARGV[2] into KEYS[1] and sets
+// its expiry to ARGV[1] seconds, the expiry is not set if a HSET fails
+const hsetExpireScript = `for i = 2, #ARGV, 2 do
+redis.call("HSET", KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return redis.call("EXPIRE", KEYS[1], ARGV[1])`
+
+// hset writes fields with HSET and sets the expiry in the same script
+func (d *db) hset(ctx context.Context, key string, fields map[string][]byte, expires int) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if expires <= 0 {
+		args := []interface{}{key}
+		for name, data := range fields {
+			args = append(args, name, data)
+		}
+		_, err := d.do(ctx, "HSET", args...)
+		return err
+	}
+
+	args := []interface{}{key, expires}
+	for name, data := range fields {
+		args = append(args, name, data)
+	}
+	_, err := d.doScript(ctx, hsetExpireScr, args...)
+	return err
+}
+
+func (d *db) HSetCtx(ctx context.Context, key, field string, value interface{}, expires int) error {
+	data, err := d.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return d.hset(ctx, key, map[string][]byte{field: data}, expires)
+}
+
+func (d *db) HMSetCtx(ctx context.Context, key string, values interface{}, expires int) error {
+	fields, err := encodeHash(d.codec, values)
+	if err != nil {
+		return err
+	}
+
+	return d.hset(ctx, key, fields, expires)
+}
+
+func (d *db) HGetCtx(ctx context.Context, key, field string, v interface{}) error {
+	data, err := redis.Bytes(d.do(ctx, "HGET", key, field))
+	if err != nil {
+		if err == redis.ErrNil {
+			return hashNotFoundError()
+		}
+		return err
+	}
+
+	return d.codec.Unmarshal(data, v)
+}
+
+func (d *db) HMGetCtx(ctx context.Context, key string, fields []string, outputs []interface{}) ([]bool, error) {
+	if len(fields) != len(outputs) {
+		return nil, errors.Errorf("the lenght of fields and outputs is not equal %d != %d", len(fields), len(outputs))
+	}
+	if len(fields) == 0 {
+		return []bool{}, nil
+	}
+
+	args := []interface{}{key}
+	for i := range fields {
+		args = append(args, fields[i])
+	}
+
+	values, err := redis.Values(d.do(ctx, "HMGET", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMGet(d.codec, values, outputs)
+}
+
+func (d *db) HGetAllCtx(ctx context.Context, key string, v interface{}) error {
+	values, err := redis.Values(d.do(ctx, "HGETALL", key))
+	if err != nil {
+		return err
+	}
+
+	if len(values) == 0 {
+		return errors.NewWithTag("key doesn't exists", errcode.ResNotFound)
+	}
+
+	fields := make(map[string][]byte)
+	for i := 0; i+1 < len(values); i += 2 {
+		name, _ := redis.String(values[i], nil)
+		fields[name], _ = redis.Bytes(values[i+1], nil)
+	}
+
+	return decodeHash(d.codec, fields, v)
+}
+
+func (d *db) HDelCtx(ctx context.Context, key string, fields ...string) (int, error) {
+	args := []interface{}{key}
+	for i := range fields {
+		args = append(args, fields[i])
+	}
+
+	return redis.Int(d.do(ctx, "HDEL", args...))
+}
+
+func (d *db) HIncrByCtx(ctx context.Context, key, field string, step int64) (int64, error) {
+	return redis.Int64(d.do(ctx, "HINCRBY", key, field, step))
+}
+
+func (d *db) HExistsCtx(ctx context.Context, key, field string) (bool, error) {
+	return redis.Bool(d.do(ctx, "HEXISTS", key, field))
+}
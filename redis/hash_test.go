@@ -0,0 +1,109 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashOp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	runHashSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func TestMemoryHashOp(t *testing.T) {
+	runHashSuite(t, NewMemoryDB(nil))
+}
+
+func runHashSuite(t *testing.T, db DB) {
+	type User struct {
+		Name   string   `redis:"name"`
+		Age    int      `redis:"age"`
+		Tags   []string `redis:"tags"`
+		Secret string   `redis:"-"`
+		Visits int64
+		hidden string
+	}
+
+	user := User{Name: "jack", Age: 18, Tags: []string{"a", "b"}, Secret: "s", Visits: 3, hidden: "h"}
+	assert.NoError(t, db.HMSet("h1", user, 0))
+
+	check := User{}
+	assert.NoError(t, db.HGetAll("h1", &check))
+	assert.Equal(t, User{Name: "jack", Age: 18, Tags: []string{"a", "b"}, Visits: 3}, check)
+
+	exists, err := db.HExists("h1", "Secret")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = db.HExists("h1", "Visits")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	name := ""
+	assert.NoError(t, db.HGet("h1", "name", &name))
+	assert.Equal(t, "jack", name)
+
+	err = db.HGet("h1", "nx", &name)
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+	err = db.HGetAll("hx", &check)
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+	assert.Error(t, db.HGetAll("h1", check))
+
+	visits, err := db.HIncrBy("h1", "Visits", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), visits)
+	_, err = db.HIncrBy("h1", "name", 1)
+	assert.Error(t, err)
+
+	age := 0
+	found, err := db.HMGet("h1", []string{"age", "nx", "Visits"}, []interface{}{&age, &name, &visits})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true}, found)
+	assert.Equal(t, 18, age)
+	assert.Equal(t, int64(5), visits)
+
+	count, err := db.HDel("h1", "age", "nx")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// fields missing in the hash are left untouched
+	check = User{Age: 99}
+	assert.NoError(t, db.HGetAll("h1", &check))
+	assert.Equal(t, 99, check.Age)
+
+	count, err = db.HDel("h1", "name", "tags", "Visits")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	exists, err = db.Exists("h1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, db.HSet("h2", "f1", 1, 100))
+	assert.NoError(t, db.HMSet("h2", map[string]interface{}{"f2": "v2"}, 0))
+	ttl, err := db.TTL("h2")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 0)
+
+	assert.Error(t, db.HMSet("h3", 1, 0))
+	assert.Error(t, db.HMSet("h3", map[int]string{1: "1"}, 0))
+
+	assert.NoError(t, db.Set("s1", 1, 0))
+	assert.Error(t, db.HSet("s1", "f1", 1, 0))
+	// a failed write does not set the expiry of the key
+	assert.Error(t, db.HSet("s1", "f1", 1, 100))
+	assert.Error(t, db.HMSet("s1", map[string]interface{}{"f1": 1}, 100))
+	ttl, err = db.TTL("s1")
+	assert.NoError(t, err)
+	assert.Equal(t, -1, ttl)
+}
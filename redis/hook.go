@@ -0,0 +1,225 @@
+package redis
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/chenjie4255/tools/log"
+	"github.com/gomodule/redigo/redis"
+)
+
+// CommandInfo 一条redis命令的信息
+type CommandInfo struct {
+	// Name 大写的命令名，如GET、EVALSHA
+	Name string
+	// Key 命令的第一个key，没有key的命令为空
+	Key string
+	// Duration 和Err只在AfterCommand中有效
+	// Pipelined commands are timed from Send to their Receive.
+	Duration time.Duration
+	Err      error
+}
+
+// Hook 在每条命令执行前后被调用
+// The context returned by BeforeCommand is passed to AfterCommand of the same hook,
+// so it can carry a trace span or a start time.
+type Hook interface {
+	BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context
+	AfterCommand(ctx context.Context, cmd *CommandInfo)
+}
+
+// WithHooks 返回对每条命令调用hooks的DB，与d共享连接池
+// Subscribers and the connections of Pool() are not hooked. DBs which are not
+// backed by redis, such as the memory DB, are returned unchanged.
+func WithHooks(d DB, hooks ...Hook) DB {
+	switch v := d.(type) {
+	case *db:
+		hooked := *v
+		hooked.hooks = append(append([]Hook{}, v.hooks...), hooks...)
+		return &hooked
+	case *prefixDB:
+		if inner, ok := v.cdb.(DB); ok {
+			return WithPrefix(WithHooks(inner, hooks...), v.prefix)
+		}
+	}
+	return d
+}
+
+// getConn borrows a connection from the pool, wrapped to call the hooks of d
+func (d *db) getConn(ctx context.Context) (redis.Conn, error) {
+	conn, err := d.pool.GetContext(ctx)
+	if err != nil || len(d.hooks) == 0 {
+		return conn, err
+	}
+	return &hookedConn{Conn: conn, hooks: d.hooks}, nil
+}
+
+// hookKeylessCommands take no key, or a cursor or pattern as the first argument
+var hookKeylessCommands = map[string]bool{
+	"SCAN":    true,
+	"KEYS":    true,
+	"SELECT":  true,
+	"AUTH":    true,
+	"SCRIPT":  true,
+	"CONFIG":  true,
+	"FLUSHDB": true,
+}
+
+type hookedCall struct {
+	ctxs  []context.Context
+	info  CommandInfo
+	start time.Time
+}
+
+// hookedConn calls the hooks around every command, replies of sent commands are
+// matched to their calls in order
+type hookedConn struct {
+	redis.Conn
+	hooks   []Hook
+	pending []*hookedCall
+}
+
+func (c *hookedConn) begin(ctx context.Context, cmd string, args []interface{}) *hookedCall {
+	call := &hookedCall{info: CommandInfo{Name: strings.ToUpper(cmd)}}
+	if !hookKeylessCommands[call.info.Name] {
+		call.info.Key, _ = commandKey(call.info.Name, args)
+	}
+
+	call.ctxs = make([]context.Context, len(c.hooks))
+	for i, hook := range c.hooks {
+		call.ctxs[i] = hook.BeforeCommand(ctx, &call.info)
+	}
+	call.start = time.Now()
+	return call
+}
+
+func (c *hookedConn) end(call *hookedCall, err error) {
+	call.info.Duration = time.Since(call.start)
+	call.info.Err = err
+	for i, hook := range c.hooks {
+		hook.AfterCommand(call.ctxs[i], &call.info)
+	}
+}
+
+// endPending ends all sent commands, a redis error belongs to one of them only
+// and is not reported for the others
+func (c *hookedConn) endPending(err error) {
+	if _, ok := err.(redis.Error); ok {
+		err = nil
+	}
+	for _, call := range c.pending {
+		c.end(call, err)
+	}
+	c.pending = nil
+}
+
+func (c *hookedConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return c.DoContext(context.Background(), cmd, args...)
+}
+
+func (c *hookedConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		reply, err := redis.DoContext(c.Conn, ctx, cmd, args...)
+		c.endPending(err)
+		return reply, err
+	}
+
+	call := c.begin(ctx, cmd, args)
+	reply, err := redis.DoContext(c.Conn, ctx, cmd, args...)
+	c.endPending(err)
+	c.end(call, err)
+	return reply, err
+}
+
+func (c *hookedConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		reply, err := redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+		c.endPending(err)
+		return reply, err
+	}
+
+	call := c.begin(context.Background(), cmd, args)
+	reply, err := redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+	c.endPending(err)
+	c.end(call, err)
+	return reply, err
+}
+
+func (c *hookedConn) Send(cmd string, args ...interface{}) error {
+	call := c.begin(context.Background(), cmd, args)
+	if err := c.Conn.Send(cmd, args...); err != nil {
+		c.end(call, err)
+		return err
+	}
+	c.pending = append(c.pending, call)
+	return nil
+}
+
+// received ends the oldest sent command, replies of pubsub have no pending call
+func (c *hookedConn) received(err error) {
+	if len(c.pending) == 0 {
+		return
+	}
+	call := c.pending[0]
+	c.pending = c.pending[1:]
+	c.end(call, err)
+}
+
+func (c *hookedConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.received(err)
+	return reply, err
+}
+
+func (c *hookedConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	reply, err := redis.ReceiveContext(c.Conn, ctx)
+	c.received(err)
+	return reply, err
+}
+
+func (c *hookedConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := redis.ReceiveWithTimeout(c.Conn, timeout)
+	c.received(err)
+	return reply, err
+}
+
+func (c *hookedConn) Close() error {
+	err := c.Conn.Close()
+	c.endPending(err)
+	return err
+}
+
+// slowLogHook logs commands slower than threshold
+type slowLogHook struct {
+	logger    *log.Logger
+	threshold time.Duration
+}
+
+// NewSlowLogHook 记录耗时超过threshold的命令，l为nil时使用redis包的logger
+func NewSlowLogHook(l *log.Logger, threshold time.Duration) Hook {
+	if l == nil {
+		l = logger
+	}
+	return &slowLogHook{l, threshold}
+}
+
+func (h *slowLogHook) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context {
+	return ctx
+}
+
+func (h *slowLogHook) AfterCommand(ctx context.Context, cmd *CommandInfo) {
+	if cmd.Duration < h.threshold {
+		return
+	}
+
+	fields := log.Fields{
+		"command":  cmd.Name,
+		"key":      cmd.Key,
+		"duration": cmd.Duration.String(),
+	}
+	if cmd.Err != nil {
+		fields["error"] = cmd.Err.Error()
+	}
+	h.logger.WithFields(fields).Warn("slow redis command")
+}
@@ -0,0 +1,141 @@
+package redis
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordHook struct {
+	mu       sync.Mutex
+	before   []string
+	commands []CommandInfo
+}
+
+type recordHookKey struct{}
+
+func (h *recordHook) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.before = append(h.before, cmd.Name)
+	return context.WithValue(ctx, recordHookKey{}, cmd.Name)
+}
+
+func (h *recordHook) AfterCommand(ctx context.Context, cmd *CommandInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ctx.Value(recordHookKey{}) == cmd.Name {
+		h.commands = append(h.commands, *cmd)
+	}
+}
+
+func (h *recordHook) find(name, key string) *CommandInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.commands {
+		if h.commands[i].Name == name && h.commands[i].Key == key {
+			return &h.commands[i]
+		}
+	}
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	rec := &recordHook{}
+	metrics := NewMetricsHook("test", nil)
+	db := WithHooks(NewDB(env.RedisHost, "", 3), rec, metrics, NewSlowLogHook(nil, 0))
+
+	assert.NoError(t, db.Set("hook_k1", "v1", 0))
+	val := ""
+	assert.NoError(t, db.Get("hook_k1", &val))
+	assert.NotNil(t, rec.find("SET", "hook_k1"))
+	get := rec.find("GET", "hook_k1")
+	if assert.NotNil(t, get) {
+		assert.True(t, get.Duration > 0)
+		assert.NoError(t, get.Err)
+	}
+
+	// commands sent in a transaction are reported one by one
+	assert.NoError(t, db.MSet(map[string]interface{}{"hook_k2": 1}, 10))
+	for _, name := range []string{"MULTI", "SET", "EXEC"} {
+		assert.NotNil(t, rec.find(name, map[string]string{"SET": "hook_k2"}[name]), name)
+	}
+	assert.NoError(t, db.HMSet("hook_hash", map[string]int{"f1": 1}, 10))
+	assert.NotNil(t, rec.find("EVALSHA", "hook_hash"))
+
+	pl := db.Pipeline()
+	pl.IncrByUint64("hook_counter", 1)
+	assert.NoError(t, pl.Exec())
+	assert.NotNil(t, rec.find("INCRBY", "hook_counter"))
+
+	// a redis error of a wrong type
+	assert.Error(t, db.HGet("hook_k1", "f1", &val))
+	hget := rec.find("HGET", "hook_k1")
+	if assert.NotNil(t, hget) {
+		assert.Error(t, hget.Err)
+	}
+
+	rec.mu.Lock()
+	assert.Equal(t, len(rec.before), len(rec.commands))
+	rec.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	_, err := metrics.WriteTo(buf)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `test_redis_commands_total{command="GET"} 1`)
+	assert.Contains(t, buf.String(), `test_redis_command_duration_seconds_count{command="SET"} 2`)
+	assert.Contains(t, buf.String(), `test_redis_command_errors_total{command="HGET"} 1`)
+
+	// the prefix is kept
+	svc := WithHooks(WithPrefix(NewDB(env.RedisHost, "", 3), "svc:"), rec)
+	assert.NoError(t, svc.Set("k1", "v1", 0))
+	assert.NotNil(t, rec.find("SET", "svc:k1"))
+}
+
+func TestMemoryHooks(t *testing.T) {
+	db := NewMemoryDB(nil)
+	assert.Equal(t, db, WithHooks(db, &recordHook{}))
+}
+
+func TestMetricsHook(t *testing.T) {
+	h := NewMetricsHook("", []float64{0.1, 0.01})
+	ctx := context.Background()
+	h.AfterCommand(ctx, &CommandInfo{Name: "GET", Key: "k", Duration: 5 * time.Millisecond})
+	h.AfterCommand(ctx, &CommandInfo{Name: "GET", Key: "k", Duration: 50 * time.Millisecond, Err: errors.New("fail")})
+	h.AfterCommand(ctx, &CommandInfo{Name: "DEL", Key: "k", Duration: time.Second})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"))
+
+	expected := []string{
+		`redis_command_duration_seconds_bucket{command="DEL",le="0.01"} 0`,
+		`redis_command_duration_seconds_bucket{command="DEL",le="+Inf"} 1`,
+		`redis_command_duration_seconds_bucket{command="GET",le="0.01"} 1`,
+		`redis_command_duration_seconds_bucket{command="GET",le="0.1"} 2`,
+		`redis_command_duration_seconds_sum{command="GET"} 0.055`,
+		`redis_commands_total{command="GET"} 2`,
+		`redis_command_errors_total{command="GET"} 1`,
+		`redis_command_errors_total{command="DEL"} 0`,
+	}
+	for _, line := range expected {
+		assert.Contains(t, w.Body.String(), line+"\n")
+	}
+}
@@ -1,6 +1,10 @@
 package redis
 
-import "github.com/gomodule/redigo/redis"
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+)
 
 type FetchFunc func() (interface{}, error)
 
@@ -10,10 +14,26 @@ type DB interface {
 	SetNotExists(key string, value interface{}, expires int) error
 	Del(key string) error
 
+	// MGet found[i] is false when keys[i] does not exist, outputs[i] is untouched then
+	MGet(keys []string, outputs []interface{}) (found []bool, err error)
+	MSet(values map[string]interface{}, expires int) error
+
 	// DelByKeys deprecated Use DelKeysByScan instead
 	DelByKeys(keyPattern string) error
 
 	DelKeysByScan(keyPattern string) error
+	// DelKeysByScanCount 按模式扫描并UNLINK，count为每批SCAN的COUNT
+	DelKeysByScanCount(keyPattern string, count int) error
+
+	// ScanKeys 按模式分批遍历key，count为每批的COUNT提示，不大于0时为100
+	// fn is called with every non-empty batch, the iteration stops with the error fn
+	// returns. A key may be seen more than once, keys added or removed during the
+	// iteration may be missed.
+	ScanKeys(pattern string, count int, fn func(keys []string) error) error
+	SScan(key, pattern string, count int, fn func(members [][]byte) error) error
+	ZScan(key, pattern string, count int, fn func(members []ZMember) error) error
+	// HScan values are encoded by the Codec of the DB
+	HScan(key, pattern string, count int, fn func(fields map[string][]byte) error) error
 	DelKeys(keys []string) error
 	DelKeyForValue(key string, value interface{}) error
 	DelKeyForBytes(key string, value []byte) error
@@ -41,9 +61,36 @@ type DB interface {
 	GetSortSetRangeStr(key string, sortKeyFrom, sortKeyTo int64) ([]string, error)
 	RemoveSortSet(key string, sortKeyFrom, sortKeyTo int64) (int, error)
 
+	// ZAdd 添加或更新成员的分数，返回新增的成员数
+	ZAdd(key string, members ...ZMember) (int, error)
+	ZIncrBy(key, member string, step float64) (float64, error)
+	// ZScore/ZRank/ZRevRank return ResNotFound if the member doesn't exist
+	ZScore(key, member string) (float64, error)
+	ZRank(key, member string) (int, error)
+	ZRevRank(key, member string) (int, error)
+	// ZRangeWithScores start/stop为排名，负数表示从末尾倒数
+	ZRangeWithScores(key string, start, stop int) ([]ZMember, error)
+	ZRevRangeWithScores(key string, start, stop int) ([]ZMember, error)
+	// ZRangeByScoreWithScores count小于0时返回offset之后的全部成员
+	ZRangeByScoreWithScores(key string, min, max float64, offset, count int) ([]ZMember, error)
+	ZRevRangeByScoreWithScores(key string, max, min float64, offset, count int) ([]ZMember, error)
+	ZRem(key string, members ...string) (int, error)
+
 	PushStringList(key string, value string, expires int) error
 	GetStringList(key string) ([]string, error)
 
+	// HSet expires大于0时同时设置整个key的过期时间
+	HSet(key, field string, value interface{}, expires int) error
+	// HMSet values can be a struct (fields named by `redis` tags) or a map keyed by string
+	HMSet(key string, values interface{}, expires int) error
+	HGet(key, field string, v interface{}) error
+	HMGet(key string, fields []string, outputs []interface{}) (found []bool, err error)
+	// HGetAll decodes the hash into a pointer to struct
+	HGetAll(key string, v interface{}) error
+	HDel(key string, fields ...string) (int, error)
+	HIncrBy(key, field string, step int64) (int64, error)
+	HExists(key, field string) (bool, error)
+
 	TTL(key string) (int, error)
 	Time() (int64, error)
 
@@ -53,14 +100,110 @@ type DB interface {
 
 	Subscribe(channels []string, done chan bool, recv func(name string, data []byte)) error
 	Publish(channel string, data []byte) (int, error)
+	// NewSubscriber 生成自动重连的订阅者，用完后需要Close
+	NewSubscriber(recv func(channel string, data []byte), opts SubscriberOptions) Subscriber
+
+	// Pipeline 将多个命令排队，在Exec时通过同一个连接发送
+	Pipeline() Pipeline
 
 	Pool() *redis.Pool
 }
 
+// ContextDB 与DB一致，但所有操作都受ctx的超时与取消控制
+type ContextDB interface {
+	GetCtx(ctx context.Context, key string, v interface{}) error
+	SetCtx(ctx context.Context, key string, value interface{}, expires int) error
+	SetNotExistsCtx(ctx context.Context, key string, value interface{}, expires int) error
+	DelCtx(ctx context.Context, key string) error
+
+	MGetCtx(ctx context.Context, keys []string, outputs []interface{}) (found []bool, err error)
+	MSetCtx(ctx context.Context, values map[string]interface{}, expires int) error
+
+	// DelByKeysCtx deprecated Use DelKeysByScanCtx instead
+	DelByKeysCtx(ctx context.Context, keyPattern string) error
+
+	DelKeysByScanCtx(ctx context.Context, keyPattern string) error
+	DelKeysByScanCountCtx(ctx context.Context, keyPattern string, count int) error
+
+	// ScanKeysCtx the iteration stops when ctx is done
+	ScanKeysCtx(ctx context.Context, pattern string, count int, fn func(keys []string) error) error
+	SScanCtx(ctx context.Context, key, pattern string, count int, fn func(members [][]byte) error) error
+	ZScanCtx(ctx context.Context, key, pattern string, count int, fn func(members []ZMember) error) error
+	HScanCtx(ctx context.Context, key, pattern string, count int, fn func(fields map[string][]byte) error) error
+	DelKeysCtx(ctx context.Context, keys []string) error
+	DelKeyForValueCtx(ctx context.Context, key string, value interface{}) error
+	DelKeyForBytesCtx(ctx context.Context, key string, value []byte) error
+	ReplaceValueCtx(ctx context.Context, key string, oldValid, newValue interface{}) error
+
+	CmpAndSetCtx(ctx context.Context, cmpKey string, cmpValue interface{}, setKey string, setValue interface{}) error
+
+	CmpGTDecrCtx(ctx context.Context, cmpKey string, greatThan int64) (int64, error)
+
+	GetLockCtx(ctx context.Context, key string, seconds int) (string, error)
+	DelLockCtx(ctx context.Context, key string, lockID string) error
+	SetLockTTLCtx(ctx context.Context, key string, lockID string, ttl int) error
+	IncrByUint64Ctx(ctx context.Context, key string, step uint64) (uint64, error)
+	IncrToUint64Ctx(ctx context.Context, key string, val uint64) (uint64, error)
+	DecrByUint64Ctx(ctx context.Context, key string, step uint64) (uint64, error)
+
+	ExistsCtx(ctx context.Context, key string) (bool, error)
+
+	CacheGetCtx(ctx context.Context, key string, v interface{}, fn FetchFunc, expires int) error
+	CacheGetBCtx(ctx context.Context, key string, v interface{}, fn FetchFunc, expires int) (bool, error)
+
+	AddSortSetStrCtx(ctx context.Context, key string, value string, sortKey int64) error
+	GetSortSetCountCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) (int, error)
+	GetSortSetRangeStrCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) ([]string, error)
+	RemoveSortSetCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) (int, error)
+
+	ZAddCtx(ctx context.Context, key string, members ...ZMember) (int, error)
+	ZIncrByCtx(ctx context.Context, key, member string, step float64) (float64, error)
+	ZScoreCtx(ctx context.Context, key, member string) (float64, error)
+	ZRankCtx(ctx context.Context, key, member string) (int, error)
+	ZRevRankCtx(ctx context.Context, key, member string) (int, error)
+	ZRangeWithScoresCtx(ctx context.Context, key string, start, stop int) ([]ZMember, error)
+	ZRevRangeWithScoresCtx(ctx context.Context, key string, start, stop int) ([]ZMember, error)
+	ZRangeByScoreWithScoresCtx(ctx context.Context, key string, min, max float64, offset, count int) ([]ZMember, error)
+	ZRevRangeByScoreWithScoresCtx(ctx context.Context, key string, max, min float64, offset, count int) ([]ZMember, error)
+	ZRemCtx(ctx context.Context, key string, members ...string) (int, error)
+
+	PushStringListCtx(ctx context.Context, key string, value string, expires int) error
+	GetStringListCtx(ctx context.Context, key string) ([]string, error)
+
+	HSetCtx(ctx context.Context, key, field string, value interface{}, expires int) error
+	HMSetCtx(ctx context.Context, key string, values interface{}, expires int) error
+	HGetCtx(ctx context.Context, key, field string, v interface{}) error
+	HMGetCtx(ctx context.Context, key string, fields []string, outputs []interface{}) (found []bool, err error)
+	HGetAllCtx(ctx context.Context, key string, v interface{}) error
+	HDelCtx(ctx context.Context, key string, fields ...string) (int, error)
+	HIncrByCtx(ctx context.Context, key, field string, step int64) (int64, error)
+	HExistsCtx(ctx context.Context, key, field string) (bool, error)
+
+	TTLCtx(ctx context.Context, key string) (int, error)
+	TimeCtx(ctx context.Context) (int64, error)
+
+	SAddCtx(ctx context.Context, key string, values ...[]byte) error
+	SRandMemberCtx(ctx context.Context, key string, count int) ([][]byte, error)
+	SCardCtx(ctx context.Context, key string) (int, error)
+
+	// SubscribeCtx returns when ctx is done or the connection is broken
+	SubscribeCtx(ctx context.Context, channels []string, done chan bool, recv func(name string, data []byte)) error
+	PublishCtx(ctx context.Context, channel string, data []byte) (int, error)
+	NewSubscriber(recv func(channel string, data []byte), opts SubscriberOptions) Subscriber
+
+	Pipeline() Pipeline
+
+	Pool() *redis.Pool
+}
+
+// WatchCBFn TypedShareInfo的变化回调
 type WatchCBFn func(delete bool, data []byte)
 
 type ShareInfo interface {
 	Set(val string) error
 	Get() (string, error)
 	Del(oldVal string) error
+
+	// Close 停止刷新和订阅
+	Close() error
 }
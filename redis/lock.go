@@ -1,9 +1,9 @@
 package redis
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
 	"github.com/chenjie4255/errors"
 	"github.com/gomodule/redigo/redis"
 )
@@ -19,9 +19,9 @@ func randomValue() string {
 	return hex.EncodeToString(buf)
 }
 
-func (d *db) GetLock(key string, seconds int) (string, error) {
+func (d *db) GetLockCtx(ctx context.Context, key string, seconds int) (string, error) {
 	value := randomValue()
-	if err := d.SetNotExists(key, value, seconds); err != nil {
+	if err := d.SetNotExistsCtx(ctx, key, value, seconds); err != nil {
 		return "", err
 	}
 	return value, nil
@@ -33,14 +33,16 @@ else
 return 0
 end`
 
-func (d *db) SetLockTTL(key string, lockID string, seconds int) error {
-	scr := redis.NewScript(1, setLockTTLScript)
-	conn := d.pool.Get()
-	defer conn.Close()
+const pexpireIfEqualScript = `if redis.call("get",KEYS[1]) == ARGV[1] then
+return redis.call("PEXPIRE",KEYS[1], ARGV[2])
+else
+return 0
+end`
 
-	lockIDData, _ := json.Marshal(lockID)
+func (d *db) SetLockTTLCtx(ctx context.Context, key string, lockID string, seconds int) error {
+	lockIDData, _ := d.codec.Marshal(lockID)
 
-	ret, err := redis.Int(scr.Do(conn, key, lockIDData, seconds))
+	ret, err := redis.Int(d.doScript(ctx, ExpireIfEqualScript, key, lockIDData, seconds))
 	if err != nil {
 		return err
 	}
@@ -52,8 +54,8 @@ func (d *db) SetLockTTL(key string, lockID string, seconds int) error {
 	return nil
 }
 
-func (l *db) DelLock(key string, lockID string) error {
-	return l.DelKeyForValue(key, lockID)
+func (l *db) DelLockCtx(ctx context.Context, key string, lockID string) error {
+	return l.DelKeyForValueCtx(ctx, key, lockID)
 }
 
 func (l *db) Pool() *redis.Pool {
@@ -19,9 +19,15 @@ func TestDLock(t *testing.T) {
 		t.Skip("env TEST_REDIS_HOST cannot be found, skip this test")
 	}
 
-	Convey("building test env", t, func() {
+	runDLockSuite(t, func() DB {
 		FlushDB(env.RedisHost, "", 0)
-		lock := NewDB(env.RedisHost, env.RedisPassword, 0)
+		return NewDB(env.RedisHost, env.RedisPassword, 0)
+	}, time.Sleep)
+}
+
+func runDLockSuite(t *testing.T, newDB func() DB, sleep func(time.Duration)) {
+	Convey("building test env", t, func() {
+		lock := newDB()
 
 		uv4, _ := uuid.NewV4()
 		randomKey := uv4.NoHyphenString()
@@ -56,7 +62,7 @@ func TestDLock(t *testing.T) {
 
 			Convey("reset exipre time as 3s and sleep 2s", func() {
 				lock.SetLockTTL(randomKey, lockID, 3)
-				time.Sleep(2 * time.Second)
+				sleep(2 * time.Second)
 
 				Convey("key should cannot be deleted", func() {
 					err := lock.DelLock(randomKey, lockID)
@@ -65,7 +71,7 @@ func TestDLock(t *testing.T) {
 			})
 
 			Convey("after 3s", func() {
-				time.Sleep(3 * time.Second)
+				sleep(3 * time.Second)
 
 				Convey("should cannot be renew expired time", func() {
 					err := lock.SetLockTTL(randomKey, lockID, 1)
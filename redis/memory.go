@@ -0,0 +1,1353 @@
+package redis
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/clock"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/gor"
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	errMemWrongType   = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errMemNotInteger  = errors.New("ERR value is not an integer or out of range")
+	errMemNegativeInt = errors.New("redigo: unexpected value for Uint64")
+	errMemDelNoArgs   = errors.New("ERR wrong number of arguments for 'del' command")
+)
+
+type memKind int
+
+const (
+	memString memKind = iota
+	memList
+	memSet
+	memSortSet
+	memHash
+)
+
+type memItem struct {
+	kind     memKind
+	str      []byte
+	list     [][]byte
+	set      map[string]struct{}
+	zset     map[string]float64
+	hash     map[string][]byte
+	expireAt int64 // unix timestamp, 0 means never expire
+}
+
+type memMessage struct {
+	channel string
+	data    []byte
+}
+
+type memSubscriber struct {
+	channels map[string]bool
+	patterns map[string]bool
+
+	mu     sync.Mutex
+	queue  []memMessage
+	notify chan struct{}
+}
+
+func (s *memSubscriber) push(msg memMessage) {
+	s.mu.Lock()
+	s.queue = append(s.queue, msg)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *memSubscriber) matchPatterns(channel string) bool {
+	for pattern := range s.patterns {
+		if matchPattern(pattern, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memSubscriber) pop() []memMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := s.queue
+	s.queue = nil
+	return ret
+}
+
+// memoryDB 基于内存的DB实现，行为与db保持一致，用于单元测试
+type memoryDB struct {
+	mu    sync.Mutex
+	clock clock.Clock
+	codec Codec
+	items map[string]*memItem
+	subs  []*memSubscriber
+}
+
+// NewMemoryDB 生成基于内存的DB，过期时间由clk决定，clk为nil时使用当前时间
+// Pool() of the returned DB is always nil.
+func NewMemoryDB(clk clock.Clock) DB {
+	return NewMemoryDBWithCodec(clk, JSONCodec)
+}
+
+// NewMemoryDBWithCodec 生成使用指定Codec编码value的内存DB
+func NewMemoryDBWithCodec(clk clock.Clock, codec Codec) DB {
+	if clk == nil {
+		clk = clock.NewOffsetClock(0)
+	}
+
+	return &memoryDB{
+		clock: clk,
+		codec: codec,
+		items: make(map[string]*memItem),
+	}
+}
+
+// lookup returns the alive item of key, must be called with m.mu held
+func (m *memoryDB) lookup(key string) *memItem {
+	item, found := m.items[key]
+	if !found {
+		return nil
+	}
+
+	if item.expireAt != 0 && m.clock.GetUnix() >= item.expireAt {
+		delete(m.items, key)
+		return nil
+	}
+
+	return item
+}
+
+func (m *memoryDB) lookupKind(key string, kind memKind) (*memItem, error) {
+	item := m.lookup(key)
+	if item != nil && item.kind != kind {
+		return nil, errMemWrongType
+	}
+	return item, nil
+}
+
+func (m *memoryDB) getBytes(key string) ([]byte, bool, error) {
+	item, err := m.lookupKind(key, memString)
+	if err != nil || item == nil {
+		return nil, false, err
+	}
+	return item.str, true, nil
+}
+
+func (m *memoryDB) setBytes(key string, data []byte, expires int) {
+	item := &memItem{kind: memString, str: data}
+	if expires > 0 {
+		item.expireAt = m.clock.GetUnix() + int64(expires)
+	}
+	m.items[key] = item
+}
+
+func (m *memoryDB) expire(key string, seconds int) bool {
+	item := m.lookup(key)
+	if item == nil {
+		return false
+	}
+
+	if seconds <= 0 {
+		delete(m.items, key)
+		return true
+	}
+
+	item.expireAt = m.clock.GetUnix() + int64(seconds)
+	return true
+}
+
+func (m *memoryDB) Get(key string, v interface{}) error {
+	m.mu.Lock()
+	data, found, err := m.getBytes(key)
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.NewWithTag("key doesn't exists", errcode.ResNotFound)
+	}
+
+	return m.codec.Unmarshal(data, v)
+}
+
+func (m *memoryDB) Set(key string, value interface{}, expires int) error {
+	data, err := m.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setBytes(key, data, expires)
+	return nil
+}
+
+func (m *memoryDB) MGet(keys []string, outputs []interface{}) ([]bool, error) {
+	if len(keys) != len(outputs) {
+		return nil, errors.Errorf("the lenght of keys and outputs is not equal %d != %d", len(keys), len(outputs))
+	}
+
+	m.mu.Lock()
+	values := make([]interface{}, len(keys))
+	for i := range keys {
+		data, found, _ := m.getBytes(keys[i])
+		if found {
+			values[i] = data
+		}
+	}
+	m.mu.Unlock()
+
+	return decodeMGet(m.codec, values, outputs)
+}
+
+func (m *memoryDB) MSet(values map[string]interface{}, expires int) error {
+	data := make(map[string][]byte, len(values))
+	for key, value := range values {
+		item, err := m.codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data[key] = item
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range data {
+		m.setBytes(key, data[key], expires)
+	}
+	return nil
+}
+
+func (m *memoryDB) SetNotExists(key string, value interface{}, expires int) error {
+	data, err := m.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lookup(key) != nil {
+		return errors.NewWithTag("resources exists", errcode.ResExisted)
+	}
+
+	m.setBytes(key, data, expires)
+	return nil
+}
+
+func (m *memoryDB) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lookup(key) != nil, nil
+}
+
+func (m *memoryDB) Del(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.items, key)
+	return nil
+}
+
+func (m *memoryDB) matchKeys(keyPattern string) []string {
+	ret := []string{}
+	for key := range m.items {
+		if m.lookup(key) != nil && matchPattern(keyPattern, key) {
+			ret = append(ret, key)
+		}
+	}
+	return ret
+}
+
+func (m *memoryDB) DelByKeys(keyPattern string) error {
+	return m.DelKeysByScan(keyPattern)
+}
+
+func (m *memoryDB) DelKeysByScan(keyPattern string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.matchKeys(keyPattern) {
+		delete(m.items, key)
+	}
+	return nil
+}
+
+func (m *memoryDB) DelKeysByScanCount(keyPattern string, count int) error {
+	return m.DelKeysByScan(keyPattern)
+}
+
+// scanBatches calls fn with count items at a time, no lock is held while fn runs
+func scanBatches(n, count int, fn func(start, end int) error) error {
+	count = scanCount(count)
+	for start := 0; start < n; start += count {
+		end := start + count
+		if end > n {
+			end = n
+		}
+		if err := fn(start, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryDB) ScanKeys(pattern string, count int, fn func(keys []string) error) error {
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	m.mu.Lock()
+	keys := m.matchKeys(pattern)
+	m.mu.Unlock()
+	sort.Strings(keys)
+
+	return scanBatches(len(keys), count, func(start, end int) error {
+		return fn(keys[start:end])
+	})
+}
+
+func (m *memoryDB) SScan(key, pattern string, count int, fn func(members [][]byte) error) error {
+	m.mu.Lock()
+	item, err := m.lookupKind(key, memSet)
+	members := [][]byte{}
+	if item != nil {
+		for member := range item.set {
+			if pattern == "" || matchPattern(pattern, member) {
+				members = append(members, []byte(member))
+			}
+		}
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return scanBatches(len(members), count, func(start, end int) error {
+		return fn(members[start:end])
+	})
+}
+
+func (m *memoryDB) ZScan(key, pattern string, count int, fn func(members []ZMember) error) error {
+	m.mu.Lock()
+	all, err := m.sortedMembersOf(key, false)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	members := []ZMember{}
+	for _, zm := range all {
+		if pattern == "" || matchPattern(pattern, zm.Member) {
+			members = append(members, zm)
+		}
+	}
+
+	return scanBatches(len(members), count, func(start, end int) error {
+		return fn(members[start:end])
+	})
+}
+
+func (m *memoryDB) HScan(key, pattern string, count int, fn func(fields map[string][]byte) error) error {
+	m.mu.Lock()
+	item, err := m.lookupKind(key, memHash)
+	names := []string{}
+	values := map[string][]byte{}
+	if item != nil {
+		for name, data := range item.hash {
+			if pattern == "" || matchPattern(pattern, name) {
+				names = append(names, name)
+				values[name] = data
+			}
+		}
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	return scanBatches(len(names), count, func(start, end int) error {
+		fields := make(map[string][]byte, end-start)
+		for _, name := range names[start:end] {
+			fields[name] = values[name]
+		}
+		return fn(fields)
+	})
+}
+
+func (m *memoryDB) DelKeys(keys []string) error {
+	if len(keys) == 0 {
+		return errMemDelNoArgs
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.items, key)
+	}
+	return nil
+}
+
+func (m *memoryDB) DelKeyForValue(key string, value interface{}) error {
+	data, err := m.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return m.DelKeyForBytes(key, data)
+}
+
+func (m *memoryDB) DelKeyForBytes(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, found, err := m.getBytes(key)
+	if err != nil {
+		return err
+	}
+
+	if !found || string(data) != string(value) {
+		return errors.New("key does not exist or unmatched")
+	}
+
+	delete(m.items, key)
+	return nil
+}
+
+func (m *memoryDB) ReplaceValue(key string, oldValue, newValue interface{}) error {
+	return m.CmpAndSet(key, oldValue, key, newValue)
+}
+
+func (m *memoryDB) CmpAndSet(cmpKey string, cmpValue interface{}, setKey string, setValue interface{}) error {
+	cmpData, err := m.codec.Marshal(cmpValue)
+	if err != nil {
+		return err
+	}
+
+	setData, err := m.codec.Marshal(setValue)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, found, err := m.getBytes(cmpKey)
+	if err != nil {
+		return err
+	}
+
+	if !found || string(data) != string(cmpData) {
+		return errors.New("key does not exist or old value is not matched")
+	}
+
+	m.setBytes(setKey, setData, 0)
+	return nil
+}
+
+// incrBy 与INCRBY一致，保留原有的过期时间
+func (m *memoryDB) incrBy(key string, delta int64) (int64, error) {
+	item, err := m.lookupKind(key, memString)
+	if err != nil {
+		return 0, err
+	}
+
+	var val int64
+	if item != nil {
+		val, err = strconv.ParseInt(string(item.str), 10, 64)
+		if err != nil {
+			return 0, errMemNotInteger
+		}
+	} else {
+		item = &memItem{kind: memString}
+		m.items[key] = item
+	}
+
+	val += delta
+	item.str = []byte(strconv.FormatInt(val, 10))
+	return val, nil
+}
+
+func (m *memoryDB) CmpGTDecr(cmpKey string, greatThan int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, found, err := m.getBytes(cmpKey)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, errors.New("ERR attempt to compare nil with number")
+	}
+
+	val, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return 0, errors.New("ERR attempt to compare nil with number")
+	}
+
+	if val <= float64(greatThan) {
+		return 0, errors.New("not exists or unmatch")
+	}
+
+	return m.incrBy(cmpKey, -1)
+}
+
+func (m *memoryDB) GetLock(key string, seconds int) (string, error) {
+	value := randomValue()
+	if err := m.SetNotExists(key, value, seconds); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func (m *memoryDB) DelLock(key string, lockID string) error {
+	return m.DelKeyForValue(key, lockID)
+}
+
+func (m *memoryDB) SetLockTTL(key string, lockID string, seconds int) error {
+	lockIDData, _ := m.codec.Marshal(lockID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, found, err := m.getBytes(key)
+	if err != nil {
+		return err
+	}
+
+	if !found || string(data) != string(lockIDData) {
+		return errors.New("key does not exist or has expired")
+	}
+
+	m.expire(key, seconds)
+	return nil
+}
+
+func uint64Result(val int64, err error) (uint64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if val < 0 {
+		return 0, errMemNegativeInt
+	}
+	return uint64(val), nil
+}
+
+func (m *memoryDB) IncrByUint64(key string, step uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return uint64Result(m.incrBy(key, int64(step)))
+}
+
+func (m *memoryDB) DecrByUint64(key string, step uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return uint64Result(m.incrBy(key, -int64(step)))
+}
+
+// IncrToUint64 keeps the lua script's semantics, which compares values as strings
+func (m *memoryDB) IncrToUint64(key string, val uint64) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, found, err := m.getBytes(key)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, errors.New("ERR attempt to compare boolean with string")
+	}
+
+	newData := strconv.FormatUint(val, 10)
+	if string(data) >= newData {
+		return 0, nil
+	}
+
+	m.setBytes(key, []byte(newData), 0)
+	return val, nil
+}
+
+func (m *memoryDB) CacheGet(key string, v interface{}, fn FetchFunc, expires int) error {
+	_, err := m.CacheGetB(key, v, fn, expires)
+	return err
+}
+
+// CacheGetB writes the fetched value back synchronously, so the result is deterministic in tests
+func (m *memoryDB) CacheGetB(key string, v interface{}, fn FetchFunc, expires int) (bool, error) {
+	if err := m.Get(key, v); err == nil {
+		// cache hit
+		return true, nil
+	}
+
+	data, err := fn()
+	if err != nil {
+		return false, err
+	}
+
+	rawData, _ := m.codec.Marshal(data)
+	m.mu.Lock()
+	m.setBytes(key, rawData, expires)
+	m.mu.Unlock()
+
+	return false, m.codec.Unmarshal(rawData, v)
+}
+
+// sortedMembers returns members ordered by score, then by member
+func (item *memItem) sortedMembers() []ZMember {
+	ret := make([]ZMember, 0, len(item.zset))
+	for member, score := range item.zset {
+		ret = append(ret, ZMember{member, score})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Score != ret[j].Score {
+			return ret[i].Score < ret[j].Score
+		}
+		return ret[i].Member < ret[j].Member
+	})
+	return ret
+}
+
+func (m *memoryDB) AddSortSetStr(key string, value string, sortKey int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memSortSet)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		item = &memItem{kind: memSortSet, zset: make(map[string]float64)}
+		m.items[key] = item
+	}
+
+	_, existed := item.zset[value]
+	item.zset[value] = float64(sortKey)
+	if existed {
+		return errors.NewWithTag("item exists", errcode.ResExisted)
+	}
+
+	return nil
+}
+
+func (m *memoryDB) rangeSortSet(key string, sortKeyFrom, sortKeyTo int64) ([]ZMember, error) {
+	item, err := m.lookupKind(key, memSortSet)
+	if err != nil || item == nil {
+		return nil, err
+	}
+
+	ret := []ZMember{}
+	for _, zm := range item.sortedMembers() {
+		if zm.Score >= float64(sortKeyFrom) && zm.Score <= float64(sortKeyTo) {
+			ret = append(ret, zm)
+		}
+	}
+	return ret, nil
+}
+
+func (m *memoryDB) GetSortSetCount(key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members, err := m.rangeSortSet(key, sortKeyFrom, sortKeyTo)
+	return len(members), err
+}
+
+func (m *memoryDB) GetSortSetRangeStr(key string, sortKeyFrom, sortKeyTo int64) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members, err := m.rangeSortSet(key, sortKeyFrom, sortKeyTo)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, zm := range members {
+		ret = append(ret, zm.Member)
+	}
+	return ret, nil
+}
+
+func (m *memoryDB) RemoveSortSet(key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members, err := m.rangeSortSet(key, sortKeyFrom, sortKeyTo)
+	if err != nil || len(members) == 0 {
+		return 0, err
+	}
+
+	item := m.items[key]
+	for _, zm := range members {
+		delete(item.zset, zm.Member)
+	}
+	if len(item.zset) == 0 {
+		delete(m.items, key)
+	}
+
+	return len(members), nil
+}
+
+func (m *memoryDB) ZAdd(key string, members ...ZMember) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memSortSet)
+	if err != nil {
+		return 0, err
+	}
+	if item == nil {
+		item = &memItem{kind: memSortSet, zset: make(map[string]float64)}
+		m.items[key] = item
+	}
+
+	count := 0
+	for _, zm := range members {
+		if _, existed := item.zset[zm.Member]; !existed {
+			count++
+		}
+		item.zset[zm.Member] = zm.Score
+	}
+	return count, nil
+}
+
+func (m *memoryDB) ZIncrBy(key, member string, step float64) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memSortSet)
+	if err != nil {
+		return 0, err
+	}
+	if item == nil {
+		item = &memItem{kind: memSortSet, zset: make(map[string]float64)}
+		m.items[key] = item
+	}
+
+	item.zset[member] += step
+	return item.zset[member], nil
+}
+
+func (m *memoryDB) ZScore(key, member string) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memSortSet)
+	if err != nil {
+		return 0, err
+	}
+	if item == nil {
+		return 0, zMemberNotFoundError()
+	}
+
+	score, found := item.zset[member]
+	if !found {
+		return 0, zMemberNotFoundError()
+	}
+	return score, nil
+}
+
+// sortedMembersOf returns members of a sorted set in ascending or descending order
+func (m *memoryDB) sortedMembersOf(key string, reverse bool) ([]ZMember, error) {
+	item, err := m.lookupKind(key, memSortSet)
+	if err != nil || item == nil {
+		return nil, err
+	}
+
+	members := item.sortedMembers()
+	if reverse {
+		for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+			members[i], members[j] = members[j], members[i]
+		}
+	}
+	return members, nil
+}
+
+func (m *memoryDB) zrank(key, member string, reverse bool) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members, err := m.sortedMembersOf(key, reverse)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, zm := range members {
+		if zm.Member == member {
+			return i, nil
+		}
+	}
+	return 0, zMemberNotFoundError()
+}
+
+func (m *memoryDB) ZRank(key, member string) (int, error) {
+	return m.zrank(key, member, false)
+}
+
+func (m *memoryDB) ZRevRank(key, member string) (int, error) {
+	return m.zrank(key, member, true)
+}
+
+func (m *memoryDB) zrange(key string, start, stop int, reverse bool) ([]ZMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members, err := m.sortedMembersOf(key, reverse)
+	if err != nil {
+		return nil, err
+	}
+
+	size := len(members)
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	if start > stop {
+		return []ZMember{}, nil
+	}
+	return members[start : stop+1], nil
+}
+
+func (m *memoryDB) ZRangeWithScores(key string, start, stop int) ([]ZMember, error) {
+	return m.zrange(key, start, stop, false)
+}
+
+func (m *memoryDB) ZRevRangeWithScores(key string, start, stop int) ([]ZMember, error) {
+	return m.zrange(key, start, stop, true)
+}
+
+func (m *memoryDB) zrangeByScore(key string, min, max float64, offset, count int, reverse bool) ([]ZMember, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members, err := m.sortedMembersOf(key, reverse)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []ZMember{}
+	for _, zm := range members {
+		if zm.Score < min || zm.Score > max {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if count >= 0 && len(ret) >= count {
+			break
+		}
+		ret = append(ret, zm)
+	}
+	return ret, nil
+}
+
+func (m *memoryDB) ZRangeByScoreWithScores(key string, min, max float64, offset, count int) ([]ZMember, error) {
+	return m.zrangeByScore(key, min, max, offset, count, false)
+}
+
+func (m *memoryDB) ZRevRangeByScoreWithScores(key string, max, min float64, offset, count int) ([]ZMember, error) {
+	return m.zrangeByScore(key, min, max, offset, count, true)
+}
+
+func (m *memoryDB) ZRem(key string, members ...string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memSortSet)
+	if err != nil || item == nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range members {
+		if _, found := item.zset[members[i]]; found {
+			delete(item.zset, members[i])
+			count++
+		}
+	}
+	if len(item.zset) == 0 {
+		delete(m.items, key)
+	}
+	return count, nil
+}
+
+func (m *memoryDB) PushStringList(key string, value string, expires int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memList)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		item = &memItem{kind: memList}
+		m.items[key] = item
+	}
+
+	item.list = append([][]byte{[]byte(value)}, item.list...)
+
+	if !m.expire(key, expires) {
+		return errors.NewWithTag("resources not exists", errcode.ResNotFound)
+	}
+
+	return nil
+}
+
+func (m *memoryDB) GetStringList(key string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memList)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	if item != nil {
+		for i := range item.list {
+			ret = append(ret, string(item.list[i]))
+		}
+	}
+	return ret, nil
+}
+
+func (m *memoryDB) hset(key string, fields map[string][]byte, expires int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memHash)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		item = &memItem{kind: memHash, hash: make(map[string][]byte)}
+		m.items[key] = item
+	}
+
+	for name, data := range fields {
+		item.hash[name] = data
+	}
+
+	if expires > 0 {
+		m.expire(key, expires)
+	}
+	return nil
+}
+
+func (m *memoryDB) HSet(key, field string, value interface{}, expires int) error {
+	data, err := m.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return m.hset(key, map[string][]byte{field: data}, expires)
+}
+
+func (m *memoryDB) HMSet(key string, values interface{}, expires int) error {
+	fields, err := encodeHash(m.codec, values)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return m.hset(key, fields, expires)
+}
+
+func (m *memoryDB) HGet(key, field string, v interface{}) error {
+	m.mu.Lock()
+	item, err := m.lookupKind(key, memHash)
+	var data []byte
+	found := false
+	if item != nil {
+		data, found = item.hash[field]
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if !found {
+		return hashNotFoundError()
+	}
+
+	return m.codec.Unmarshal(data, v)
+}
+
+func (m *memoryDB) HMGet(key string, fields []string, outputs []interface{}) ([]bool, error) {
+	if len(fields) != len(outputs) {
+		return nil, errors.Errorf("the lenght of fields and outputs is not equal %d != %d", len(fields), len(outputs))
+	}
+
+	m.mu.Lock()
+	item, err := m.lookupKind(key, memHash)
+	values := make([]interface{}, len(fields))
+	if item != nil {
+		for i := range fields {
+			if data, found := item.hash[fields[i]]; found {
+				values[i] = data
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMGet(m.codec, values, outputs)
+}
+
+func (m *memoryDB) HGetAll(key string, v interface{}) error {
+	m.mu.Lock()
+	item, err := m.lookupKind(key, memHash)
+	fields := make(map[string][]byte)
+	if item != nil {
+		for name, data := range item.hash {
+			fields[name] = data
+		}
+	}
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return errors.NewWithTag("key doesn't exists", errcode.ResNotFound)
+	}
+
+	return decodeHash(m.codec, fields, v)
+}
+
+func (m *memoryDB) HDel(key string, fields ...string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memHash)
+	if err != nil || item == nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range fields {
+		if _, found := item.hash[fields[i]]; found {
+			delete(item.hash, fields[i])
+			count++
+		}
+	}
+	if len(item.hash) == 0 {
+		delete(m.items, key)
+	}
+	return count, nil
+}
+
+func (m *memoryDB) HIncrBy(key, field string, step int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memHash)
+	if err != nil {
+		return 0, err
+	}
+	if item == nil {
+		item = &memItem{kind: memHash, hash: make(map[string][]byte)}
+		m.items[key] = item
+	}
+
+	var val int64
+	if data, found := item.hash[field]; found {
+		val, err = strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return 0, errors.New("ERR hash value is not an integer")
+		}
+	}
+
+	val += step
+	item.hash[field] = []byte(strconv.FormatInt(val, 10))
+	return val, nil
+}
+
+func (m *memoryDB) HExists(key, field string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memHash)
+	if err != nil || item == nil {
+		return false, err
+	}
+
+	_, found := item.hash[field]
+	return found, nil
+}
+
+func (m *memoryDB) TTL(key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item := m.lookup(key)
+	if item == nil {
+		return 0, errors.NewWithTag("key does not exists", errcode.ResNotFound)
+	}
+
+	if item.expireAt == 0 {
+		return -1, nil
+	}
+
+	return int(item.expireAt - m.clock.GetUnix()), nil
+}
+
+func (m *memoryDB) Time() (int64, error) {
+	return m.clock.GetUnix(), nil
+}
+
+func (m *memoryDB) SAdd(key string, values ...[]byte) error {
+	if len(values) == 0 {
+		return errors.New("ERR wrong number of arguments for 'sadd' command")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memSet)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		item = &memItem{kind: memSet, set: make(map[string]struct{})}
+		m.items[key] = item
+	}
+
+	for i := range values {
+		item.set[string(values[i])] = struct{}{}
+	}
+	return nil
+}
+
+// SRandMember 与SRANDMEMBER一致，count为负数时结果可能重复
+func (m *memoryDB) SRandMember(key string, count int) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memSet)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := [][]byte{}
+	if item == nil || count == 0 {
+		return ret, nil
+	}
+
+	members := make([]string, 0, len(item.set))
+	for member := range item.set {
+		members = append(members, member)
+	}
+
+	if count < 0 {
+		for i := 0; i < -count; i++ {
+			ret = append(ret, []byte(members[rand.Intn(len(members))]))
+		}
+		return ret, nil
+	}
+
+	rand.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+	if count > len(members) {
+		count = len(members)
+	}
+	for _, member := range members[:count] {
+		ret = append(ret, []byte(member))
+	}
+	return ret, nil
+}
+
+func (m *memoryDB) SCard(key string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item, err := m.lookupKind(key, memSet)
+	if err != nil || item == nil {
+		return 0, err
+	}
+	return len(item.set), nil
+}
+
+// Subscribe blocks forever like db.Subscribe, messages are delivered in publishing order
+func (m *memoryDB) Subscribe(channels []string, done chan bool, recv func(name string, data []byte)) error {
+	return m.SubscribeCtx(context.Background(), channels, done, recv)
+}
+
+// SubscribeCtx blocks until ctx is done, the subscription is removed then
+func (m *memoryDB) SubscribeCtx(ctx context.Context, channels []string, done chan bool, recv func(name string, data []byte)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	sub := &memSubscriber{
+		channels: make(map[string]bool),
+		notify:   make(chan struct{}, 1),
+	}
+	for i := range channels {
+		sub.channels[channels[i]] = true
+	}
+
+	m.mu.Lock()
+	m.subs = append(m.subs, sub)
+	m.mu.Unlock()
+
+	done <- true
+
+	if ctx.Done() != nil {
+		gor.RunWithRecover(func() {
+			<-ctx.Done()
+			m.removeSubscriber(sub)
+		})
+	}
+
+	for range sub.notify {
+		for _, msg := range sub.pop() {
+			recv(msg.channel, msg.data)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// removeSubscriber removes sub and closes its notify, which ends Subscribe
+func (m *memoryDB) removeSubscriber(sub *memSubscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.subs {
+		if m.subs[i] == sub {
+			m.subs = append(m.subs[:i], m.subs[i+1:]...)
+			break
+		}
+	}
+	close(sub.notify)
+}
+
+// Publish pushes while holding m.mu, so that no message is pushed to a removed subscriber
+func (m *memoryDB) Publish(channel string, data []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, sub := range m.subs {
+		if sub.channels[channel] || sub.matchPatterns(channel) {
+			sub.push(memMessage{channel, append([]byte(nil), data...)})
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (m *memoryDB) Pool() *redis.Pool {
+	return nil
+}
+
+// matchPattern 与redis的glob规则一致，支持 * ? [abc] [^a] [a-z] 以及 \ 转义
+func matchPattern(pattern, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(str); i++ {
+				if matchPattern(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(str) == 0 {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(str) == 0 {
+				return false
+			}
+			pattern = pattern[1:]
+			not := len(pattern) > 0 && pattern[0] == '^'
+			if not {
+				pattern = pattern[1:]
+			}
+			match := false
+			for len(pattern) > 0 && pattern[0] != ']' {
+				if pattern[0] == '\\' && len(pattern) >= 2 {
+					pattern = pattern[1:]
+					if pattern[0] == str[0] {
+						match = true
+					}
+				} else if len(pattern) >= 3 && pattern[1] == '-' {
+					start, end := pattern[0], pattern[2]
+					if start > end {
+						start, end = end, start
+					}
+					if str[0] >= start && str[0] <= end {
+						match = true
+					}
+					pattern = pattern[2:]
+				} else if pattern[0] == str[0] {
+					match = true
+				}
+				pattern = pattern[1:]
+			}
+			if len(pattern) > 0 {
+				pattern = pattern[1:]
+			}
+			if match == not {
+				return false
+			}
+			str = str[1:]
+		case '\\':
+			if len(pattern) >= 2 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(str) == 0 || pattern[0] != str[0] {
+				return false
+			}
+			str = str[1:]
+			pattern = pattern[1:]
+		}
+	}
+
+	return len(str) == 0
+}
@@ -0,0 +1,92 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/tools/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// newMemorySuiteDB returns a DB factory and a sleep func which moves the clock of the latest DB forward
+func newMemorySuiteDB() (func() DB, func(time.Duration)) {
+	var clk *clock.FixedClock
+	newDB := func() DB {
+		clk = clock.NewFixedClock(time.Now().Unix())
+		return NewMemoryDB(clk)
+	}
+	sleep := func(d time.Duration) {
+		clk.SetTs(clk.GetUnix() + int64(d/time.Second))
+	}
+	return newDB, sleep
+}
+
+func TestMemoryCacheOp(t *testing.T) {
+	newDB, sleep := newMemorySuiteDB()
+	runCacheOpSuite(t, newDB, sleep)
+}
+
+func TestMemorySliceOp(t *testing.T) {
+	newDB, _ := newMemorySuiteDB()
+	runSliceOpSuite(t, newDB)
+}
+
+func TestMemoryPubSub(t *testing.T) {
+	runPubSubSuite(t, NewMemoryDB(nil))
+}
+
+func TestMemoryDLock(t *testing.T) {
+	newDB, sleep := newMemorySuiteDB()
+	runDLockSuite(t, newDB, sleep)
+}
+
+func TestMemoryExpire(t *testing.T) {
+	clk := clock.NewFixedClock(1000)
+	db := NewMemoryDB(clk)
+
+	assert.NoError(t, db.Set("k1", "v1", 10))
+	assert.NoError(t, db.Set("k2", "v2", 0))
+
+	clk.SetTs(1009)
+	ttl, err := db.TTL("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ttl)
+
+	clk.SetTs(1010)
+	exists, err := db.Exists("k1")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	exists, err = db.Exists("k2")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	_, err = db.IncrByUint64("k2", 1)
+	assert.Error(t, err)
+}
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		str     string
+		match   bool
+	}{
+		{"*", "", true},
+		{"ite*", "item_01", true},
+		{"ite*", "xitem", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"a\\*b", "a*b", true},
+		{"a\\*b", "aab", false},
+		{"a/*", "a/b/c", true},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, matchPattern(c.pattern, c.str), "%s %s", c.pattern, c.str)
+	}
+}
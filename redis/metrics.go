@@ -0,0 +1,148 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultMetricsBuckets 命令耗时直方图默认的桶，单位秒
+var DefaultMetricsBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+type commandMetrics struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+	errors  uint64
+}
+
+// MetricsHook 按命令统计耗时直方图、调用次数和错误次数
+// The metrics are exposed in the Prometheus text format by WriteTo and ServeHTTP.
+type MetricsHook struct {
+	namespace string
+	buckets   []float64
+
+	mu       sync.Mutex
+	commands map[string]*commandMetrics
+}
+
+// NewMetricsHook 生成MetricsHook，namespace不为空时作为指标名的前缀，buckets为空时使用DefaultMetricsBuckets
+func NewMetricsHook(namespace string, buckets []float64) *MetricsHook {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	return &MetricsHook{
+		namespace: namespace,
+		buckets:   buckets,
+		commands:  map[string]*commandMetrics{},
+	}
+}
+
+func (h *MetricsHook) BeforeCommand(ctx context.Context, cmd *CommandInfo) context.Context {
+	return ctx
+}
+
+func (h *MetricsHook) AfterCommand(ctx context.Context, cmd *CommandInfo) {
+	seconds := cmd.Duration.Seconds()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	m, ok := h.commands[cmd.Name]
+	if !ok {
+		m = &commandMetrics{buckets: make([]uint64, len(h.buckets))}
+		h.commands[cmd.Name] = m
+	}
+
+	for i, le := range h.buckets {
+		if seconds <= le {
+			m.buckets[i]++
+		}
+	}
+	m.count++
+	m.sum += seconds
+	if cmd.Err != nil {
+		m.errors++
+	}
+}
+
+func (h *MetricsHook) name(metric string) string {
+	if h.namespace == "" {
+		return metric
+	}
+	return h.namespace + "_" + metric
+}
+
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	n, _ := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// WriteTo 以Prometheus文本格式输出所有指标
+func (h *MetricsHook) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	names := make([]string, 0, len(h.commands))
+	commands := make(map[string]commandMetrics, len(h.commands))
+	for name, m := range h.commands {
+		names = append(names, name)
+		snapshot := *m
+		snapshot.buckets = append([]uint64{}, m.buckets...)
+		commands[name] = snapshot
+	}
+	h.mu.Unlock()
+	sort.Strings(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	duration := h.name("redis_command_duration_seconds")
+	cw.printf("# HELP %s Duration of redis commands in seconds.\n", duration)
+	cw.printf("# TYPE %s histogram\n", duration)
+	for _, name := range names {
+		m := commands[name]
+		for i, le := range h.buckets {
+			cw.printf("%s_bucket{command=%q,le=%q} %d\n", duration, name, formatFloat(le), m.buckets[i])
+		}
+		cw.printf("%s_bucket{command=%q,le=\"+Inf\"} %d\n", duration, name, m.count)
+		cw.printf("%s_sum{command=%q} %s\n", duration, name, formatFloat(m.sum))
+		cw.printf("%s_count{command=%q} %d\n", duration, name, m.count)
+	}
+
+	total := h.name("redis_commands_total")
+	cw.printf("# HELP %s Number of redis commands.\n", total)
+	cw.printf("# TYPE %s counter\n", total)
+	for _, name := range names {
+		cw.printf("%s{command=%q} %d\n", total, name, commands[name].count)
+	}
+
+	errs := h.name("redis_command_errors_total")
+	cw.printf("# HELP %s Number of failed redis commands.\n", errs)
+	cw.printf("# TYPE %s counter\n", errs)
+	for _, name := range names {
+		cw.printf("%s{command=%q} %d\n", errs, name, commands[name].errors)
+	}
+
+	return cw.n, cw.w.Flush()
+}
+
+// ServeHTTP 输出指标，可直接挂载到/metrics
+func (h *MetricsHook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.WriteTo(w)
+}
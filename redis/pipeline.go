@@ -0,0 +1,338 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/gomodule/redigo/redis"
+)
+
+// Pipeline 批量发送命令，命令的结果在Exec之后才能读取
+// Exec only returns connection level errors, errors of a single command are kept
+// in its PipelineCmd.
+type Pipeline interface {
+	Get(key string, v interface{}) *PipelineCmd
+	Set(key string, value interface{}, expires int) *PipelineCmd
+	Del(key string) *PipelineCmd
+	Exists(key string) *PipelineCmd
+	IncrByUint64(key string, step uint64) *PipelineCmd
+	DecrByUint64(key string, step uint64) *PipelineCmd
+	TTL(key string) *PipelineCmd
+	SAdd(key string, values ...[]byte) *PipelineCmd
+	Publish(channel string, data []byte) *PipelineCmd
+
+	// Do queues a raw command
+	Do(cmd string, args ...interface{}) *PipelineCmd
+
+	Exec() error
+	ExecCtx(ctx context.Context) error
+}
+
+// PipelineCmd 管道中单个命令的结果
+type PipelineCmd struct {
+	reply interface{}
+	err   error
+}
+
+func (c *PipelineCmd) Err() error {
+	return c.err
+}
+
+func (c *PipelineCmd) Reply() (interface{}, error) {
+	return c.reply, c.err
+}
+
+func (c *PipelineCmd) Int() (int, error) {
+	return redis.Int(c.reply, c.err)
+}
+
+func (c *PipelineCmd) Int64() (int64, error) {
+	return redis.Int64(c.reply, c.err)
+}
+
+func (c *PipelineCmd) Uint64() (uint64, error) {
+	return redis.Uint64(c.reply, c.err)
+}
+
+func (c *PipelineCmd) Bool() (bool, error) {
+	return redis.Bool(c.reply, c.err)
+}
+
+func (c *PipelineCmd) String() (string, error) {
+	return redis.String(c.reply, c.err)
+}
+
+func (c *PipelineCmd) Strings() ([]string, error) {
+	return redis.Strings(c.reply, c.err)
+}
+
+var errPipelineNotExecuted = errors.New("pipeline is not executed yet")
+
+func newPipelineCmd() *PipelineCmd {
+	return &PipelineCmd{err: errPipelineNotExecuted}
+}
+
+type pipelineOp struct {
+	cmd    string
+	args   []interface{}
+	result *PipelineCmd
+
+	// decode post-processes the reply, optional
+	decode func(reply interface{}, err error) (interface{}, error)
+}
+
+type pipeline struct {
+	d   *db
+	ops []pipelineOp
+}
+
+func (d *db) Pipeline() Pipeline {
+	return &pipeline{d: d}
+}
+
+func (p *pipeline) queue(decode func(interface{}, error) (interface{}, error), cmd string, args ...interface{}) *PipelineCmd {
+	op := pipelineOp{cmd, args, newPipelineCmd(), decode}
+	p.ops = append(p.ops, op)
+	return op.result
+}
+
+func (p *pipeline) Get(key string, v interface{}) *PipelineCmd {
+	return p.queue(func(reply interface{}, err error) (interface{}, error) {
+		data, err := redis.Bytes(reply, err)
+		if err != nil {
+			if err == redis.ErrNil {
+				return nil, errors.NewWithTag("key doesn't exists", errcode.ResNotFound)
+			}
+			return nil, err
+		}
+		return data, p.d.codec.Unmarshal(data, v)
+	}, "GET", key)
+}
+
+func (p *pipeline) Set(key string, value interface{}, expires int) *PipelineCmd {
+	data, err := p.d.codec.Marshal(value)
+	if err != nil {
+		return &PipelineCmd{err: err}
+	}
+
+	if expires > 0 {
+		return p.queue(nil, "SET", key, data, "EX", expires)
+	}
+	return p.queue(nil, "SET", key, data)
+}
+
+func (p *pipeline) Del(key string) *PipelineCmd {
+	return p.queue(nil, "DEL", key)
+}
+
+func (p *pipeline) Exists(key string) *PipelineCmd {
+	return p.queue(nil, "EXISTS", key)
+}
+
+func (p *pipeline) IncrByUint64(key string, step uint64) *PipelineCmd {
+	return p.queue(nil, "INCRBY", key, step)
+}
+
+func (p *pipeline) DecrByUint64(key string, step uint64) *PipelineCmd {
+	return p.queue(nil, "DECRBY", key, step)
+}
+
+func (p *pipeline) TTL(key string) *PipelineCmd {
+	return p.queue(func(reply interface{}, err error) (interface{}, error) {
+		result, err := redis.Int64(reply, err)
+		if err == nil && result == -2 {
+			return nil, errors.NewWithTag("key does not exists", errcode.ResNotFound)
+		}
+		return result, err
+	}, "TTL", key)
+}
+
+func (p *pipeline) SAdd(key string, values ...[]byte) *PipelineCmd {
+	params := []interface{}{key}
+	for i := range values {
+		params = append(params, values[i])
+	}
+	return p.queue(nil, "SADD", params...)
+}
+
+func (p *pipeline) Publish(channel string, data []byte) *PipelineCmd {
+	return p.queue(nil, "PUBLISH", channel, data)
+}
+
+func (p *pipeline) Do(cmd string, args ...interface{}) *PipelineCmd {
+	return p.queue(nil, cmd, args...)
+}
+
+func (p *pipeline) Exec() error {
+	return p.ExecCtx(context.Background())
+}
+
+func (p *pipeline) ExecCtx(ctx context.Context) error {
+	ops := p.ops
+	p.ops = nil
+	if len(ops) == 0 {
+		return nil
+	}
+
+	fail := func(err error) error {
+		for i := range ops {
+			if ops[i].result.err == errPipelineNotExecuted {
+				ops[i].result.err = err
+			}
+		}
+		return err
+	}
+
+	conn, err := p.d.getConn(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer conn.Close()
+
+	for i := range ops {
+		if err := conn.Send(ops[i].cmd, ops[i].args...); err != nil {
+			return fail(err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return fail(err)
+	}
+
+	for i := range ops {
+		reply, err := redis.ReceiveContext(conn, ctx)
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				return fail(err)
+			}
+		}
+
+		if ops[i].decode != nil {
+			reply, err = ops[i].decode(reply, err)
+		}
+		ops[i].result.reply, ops[i].result.err = reply, err
+	}
+
+	return nil
+}
+
+// memPipeline runs queued operations one by one against a memoryDB
+type memPipeline struct {
+	m   *memoryDB
+	ops []memPipelineOp
+}
+
+type memPipelineOp struct {
+	fn     func() (interface{}, error)
+	result *PipelineCmd
+}
+
+func (m *memoryDB) Pipeline() Pipeline {
+	return &memPipeline{m: m}
+}
+
+func (p *memPipeline) queue(fn func() (interface{}, error)) *PipelineCmd {
+	op := memPipelineOp{fn, newPipelineCmd()}
+	p.ops = append(p.ops, op)
+	return op.result
+}
+
+func (p *memPipeline) Get(key string, v interface{}) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		return nil, p.m.Get(key, v)
+	})
+}
+
+func (p *memPipeline) Set(key string, value interface{}, expires int) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		return "OK", p.m.Set(key, value, expires)
+	})
+}
+
+func (p *memPipeline) Del(key string) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		exists, _ := p.m.Exists(key)
+		if err := p.m.Del(key); err != nil || !exists {
+			return int64(0), err
+		}
+		return int64(1), nil
+	})
+}
+
+func (p *memPipeline) Exists(key string) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		exists, err := p.m.Exists(key)
+		if exists {
+			return int64(1), err
+		}
+		return int64(0), err
+	})
+}
+
+func (p *memPipeline) IncrByUint64(key string, step uint64) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		p.m.mu.Lock()
+		defer p.m.mu.Unlock()
+		return p.m.incrBy(key, int64(step))
+	})
+}
+
+func (p *memPipeline) DecrByUint64(key string, step uint64) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		p.m.mu.Lock()
+		defer p.m.mu.Unlock()
+		return p.m.incrBy(key, -int64(step))
+	})
+}
+
+func (p *memPipeline) TTL(key string) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		ttl, err := p.m.TTL(key)
+		return int64(ttl), err
+	})
+}
+
+func (p *memPipeline) SAdd(key string, values ...[]byte) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		card, _ := p.m.SCard(key)
+		if err := p.m.SAdd(key, values...); err != nil {
+			return nil, err
+		}
+		newCard, err := p.m.SCard(key)
+		return int64(newCard - card), err
+	})
+}
+
+func (p *memPipeline) Publish(channel string, data []byte) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		count, err := p.m.Publish(channel, data)
+		return int64(count), err
+	})
+}
+
+func (p *memPipeline) Do(cmd string, args ...interface{}) *PipelineCmd {
+	return p.queue(func() (interface{}, error) {
+		return nil, errors.Errorf("memory db does not support raw command %s", cmd)
+	})
+}
+
+func (p *memPipeline) Exec() error {
+	return p.ExecCtx(context.Background())
+}
+
+func (p *memPipeline) ExecCtx(ctx context.Context) error {
+	ops := p.ops
+	p.ops = nil
+
+	for i := range ops {
+		if err := ctx.Err(); err != nil {
+			for j := i; j < len(ops); j++ {
+				ops[j].result.err = err
+			}
+			return err
+		}
+		ops[i].result.reply, ops[i].result.err = ops[i].fn()
+	}
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchOp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	runBatchSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func TestMemoryBatchOp(t *testing.T) {
+	runBatchSuite(t, NewMemoryDB(nil))
+}
+
+func runBatchSuite(t *testing.T, db DB) {
+	type Item struct {
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+	}
+
+	item1 := Item{"1", 1.2}
+	item2 := Item{"2", 1.3}
+	assert.NoError(t, db.MSet(map[string]interface{}{"m1": item1, "m2": item2}, 0))
+	assert.NoError(t, db.MSet(map[string]interface{}{"m3": 3}, 100))
+
+	ttl, err := db.TTL("m3")
+	assert.NoError(t, err)
+	assert.True(t, ttl > 0)
+
+	checks := make([]Item, 3)
+	found, err := db.MGet([]string{"m1", "mx", "m2"}, []interface{}{&checks[0], &checks[1], &checks[2]})
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true}, found)
+	assert.Equal(t, []Item{item1, {}, item2}, checks)
+
+	_, err = db.MGet([]string{"m1"}, nil)
+	assert.Error(t, err)
+
+	p := db.Pipeline()
+	setCmd := p.Set("p1", item1, 100)
+	getCmd := p.Get("p1", &checks[0])
+	missCmd := p.Get("px", &checks[1])
+	incrCmd := p.IncrByUint64("pc", 10)
+	decrCmd := p.DecrByUint64("pc", 3)
+	existsCmd := p.Exists("p1")
+	ttlCmd := p.TTL("px")
+	delCmd := p.Del("p1")
+
+	assert.Error(t, getCmd.Err())
+	assert.NoError(t, p.Exec())
+
+	assert.NoError(t, setCmd.Err())
+	assert.NoError(t, getCmd.Err())
+	assert.Equal(t, item1, checks[0])
+	assert.True(t, errors.FindTag(missCmd.Err(), errcode.ResNotFound))
+
+	val, err := incrCmd.Uint64()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), val)
+
+	val, err = decrCmd.Uint64()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), val)
+
+	exists, err := existsCmd.Bool()
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.True(t, errors.FindTag(ttlCmd.Err(), errcode.ResNotFound))
+
+	count, err := delCmd.Int()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// per command errors do not fail the pipeline
+	p.Set("ps", "str", 0)
+	badCmd := p.IncrByUint64("ps", 1)
+	assert.NoError(t, p.Exec())
+	assert.Error(t, badCmd.Err())
+
+	assert.NoError(t, p.Exec())
+}
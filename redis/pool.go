@@ -0,0 +1,157 @@
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// PoolOption 设置NewPool生成的连接池
+type PoolOption func(*poolConfig)
+
+type poolConfig struct {
+	username   string
+	password   string
+	db         int
+	clientName string
+
+	useTLS    bool
+	tlsConfig *tls.Config
+
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+	writeTimeout   time.Duration
+
+	maxIdle         int
+	maxActive       int
+	wait            bool
+	idleTimeout     time.Duration
+	maxConnLifetime time.Duration
+}
+
+// WithTLS 使用TLS连接，config为nil时使用默认配置
+func WithTLS(config *tls.Config) PoolOption {
+	return func(c *poolConfig) {
+		c.useTLS = true
+		c.tlsConfig = config
+	}
+}
+
+// WithUsername 使用ACL用户名认证，密码仍为password参数
+func WithUsername(username string) PoolOption {
+	return func(c *poolConfig) {
+		c.username = username
+	}
+}
+
+// WithClientName 连接后通过CLIENT SETNAME设置连接名
+func WithClientName(name string) PoolOption {
+	return func(c *poolConfig) {
+		c.clientName = name
+	}
+}
+
+// WithConnectTimeout 建立连接的超时时间
+func WithConnectTimeout(timeout time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		c.connectTimeout = timeout
+	}
+}
+
+// WithReadTimeout 读取单个回复的超时时间
+// It also bounds blocking commands and pubsub receives, so it must be longer than
+// their block time and SubscriberOptions.PingInterval.
+func WithReadTimeout(timeout time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithWriteTimeout 发送单个命令的超时时间
+func WithWriteTimeout(timeout time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		c.writeTimeout = timeout
+	}
+}
+
+// WithMaxIdle 最大空闲连接数，默认10
+func WithMaxIdle(n int) PoolOption {
+	return func(c *poolConfig) {
+		c.maxIdle = n
+	}
+}
+
+// WithMaxActive 最大连接数，0表示不限制；wait为true时连接用尽后等待，否则立即返回错误
+func WithMaxActive(n int, wait bool) PoolOption {
+	return func(c *poolConfig) {
+		c.maxActive = n
+		c.wait = wait
+	}
+}
+
+// WithIdleTimeout 空闲超过该时间的连接被关闭，默认240秒
+func WithIdleTimeout(timeout time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		c.idleTimeout = timeout
+	}
+}
+
+// WithConnLifetime 连接建立超过该时间后被关闭，0表示不限制
+func WithConnLifetime(lifetime time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		c.maxConnLifetime = lifetime
+	}
+}
+
+func newPoolConfig(password string, db int, opts []PoolOption) *poolConfig {
+	c := &poolConfig{
+		password:    password,
+		db:          db,
+		maxIdle:     10,
+		idleTimeout: 240 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// dial connects to addr, authenticates and selects the db
+func (c *poolConfig) dial(ctx context.Context, addr string) (redis.Conn, error) {
+	options := []redis.DialOption{
+		redis.DialUsername(c.username),
+		redis.DialPassword(c.password),
+		redis.DialDatabase(c.db),
+		redis.DialClientName(c.clientName),
+		redis.DialUseTLS(c.useTLS),
+		redis.DialTLSConfig(c.tlsConfig),
+		redis.DialReadTimeout(c.readTimeout),
+		redis.DialWriteTimeout(c.writeTimeout),
+	}
+	// redigo uses 30 seconds by default
+	if c.connectTimeout > 0 {
+		options = append(options, redis.DialConnectTimeout(c.connectTimeout))
+	}
+
+	return redis.DialContext(ctx, "tcp", addr, options...)
+}
+
+func (c *poolConfig) newPool(dial func(ctx context.Context) (redis.Conn, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:         c.maxIdle,
+		MaxActive:       c.maxActive,
+		Wait:            c.wait,
+		IdleTimeout:     c.idleTimeout,
+		MaxConnLifetime: c.maxConnLifetime,
+		DialContext:     dial,
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			if time.Since(t) < time.Minute {
+				return nil
+			}
+			_, err := conn.Do("PING")
+			return err
+		},
+	}
+}
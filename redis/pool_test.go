@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolOptions(t *testing.T) {
+	pool := NewPool("127.0.0.1:6379", "", 0)
+	assert.Equal(t, 10, pool.MaxIdle)
+	assert.Equal(t, 0, pool.MaxActive)
+	assert.Equal(t, 240*time.Second, pool.IdleTimeout)
+
+	pool = NewPool("127.0.0.1:6379", "pass", 1,
+		WithMaxIdle(5),
+		WithMaxActive(20, true),
+		WithIdleTimeout(time.Minute),
+		WithConnLifetime(time.Hour),
+	)
+	assert.Equal(t, 5, pool.MaxIdle)
+	assert.Equal(t, 20, pool.MaxActive)
+	assert.True(t, pool.Wait)
+	assert.Equal(t, time.Minute, pool.IdleTimeout)
+	assert.Equal(t, time.Hour, pool.MaxConnLifetime)
+
+	c := newPoolConfig("pass", 1, []PoolOption{
+		WithTLS(nil),
+		WithUsername("app"),
+		WithClientName("worker"),
+		WithConnectTimeout(time.Second),
+		WithReadTimeout(2 * time.Second),
+		WithWriteTimeout(3 * time.Second),
+	})
+	assert.True(t, c.useTLS)
+	assert.Equal(t, "app", c.username)
+	assert.Equal(t, "pass", c.password)
+	assert.Equal(t, 1, c.db)
+	assert.Equal(t, "worker", c.clientName)
+	assert.Equal(t, time.Second, c.connectTimeout)
+	assert.Equal(t, 2*time.Second, c.readTimeout)
+	assert.Equal(t, 3*time.Second, c.writeTimeout)
+}
+
+func TestPoolDial(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	pool := NewPool(env.RedisHost, "", 3,
+		WithClientName("pool_test"),
+		WithMaxActive(1, false),
+		WithReadTimeout(time.Second),
+	)
+	defer pool.Close()
+
+	conn, err := pool.GetContext(context.Background())
+	assert.NoError(t, err)
+	name, err := redis.String(conn.Do("CLIENT", "GETNAME"))
+	assert.NoError(t, err)
+	assert.Equal(t, "pool_test", name)
+
+	// the pool is exhausted
+	_, err = pool.GetContext(context.Background())
+	assert.Equal(t, redis.ErrPoolExhausted, err)
+	conn.Close()
+
+	db := NewDBFromPool(pool)
+	assert.NoError(t, db.Set("pool_key", 1, 0))
+}
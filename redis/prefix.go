@@ -0,0 +1,648 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// WithPrefix 返回一个DB，所有key、扫描模式和频道名都加上prefix
+// Several services can share one redis db without colliding on keys. Channel names
+// passed to recv are stripped of the prefix again. Pool() and Pipeline().Do are not
+// prefixed, use PrefixedKey for the keys sent through them. The returned DB also
+// implements ContextDB.
+func WithPrefix(d DB, prefix string) DB {
+	return &prefixDB{AdaptContextDB(d), prefix}
+}
+
+// PrefixedKey 返回key在db中实际使用的名字，db不是WithPrefix生成的时原样返回
+func PrefixedKey(d DB, key string) string {
+	if p, ok := d.(*prefixDB); ok {
+		return p.key(key)
+	}
+	return key
+}
+
+// escapePattern escapes the glob characters of s so that it only matches itself
+func escapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+type prefixDB struct {
+	cdb    ContextDB
+	prefix string
+}
+
+func (p *prefixDB) key(key string) string {
+	return p.prefix + key
+}
+
+func (p *prefixDB) keys(keys []string) []string {
+	ret := make([]string, len(keys))
+	for i := range keys {
+		ret[i] = p.prefix + keys[i]
+	}
+	return ret
+}
+
+func (p *prefixDB) pattern(pattern string) string {
+	return escapePattern(p.prefix) + pattern
+}
+
+func (p *prefixDB) recv(recv func(name string, data []byte)) func(name string, data []byte) {
+	return func(name string, data []byte) {
+		recv(strings.TrimPrefix(name, p.prefix), data)
+	}
+}
+
+// The DB methods of prefixDB are bound to context.Background() like the ones of db
+
+func (p *prefixDB) Get(key string, v interface{}) error {
+	return p.GetCtx(context.Background(), key, v)
+}
+
+func (p *prefixDB) Set(key string, value interface{}, expires int) error {
+	return p.SetCtx(context.Background(), key, value, expires)
+}
+
+func (p *prefixDB) SetNotExists(key string, value interface{}, expires int) error {
+	return p.SetNotExistsCtx(context.Background(), key, value, expires)
+}
+
+func (p *prefixDB) Del(key string) error {
+	return p.DelCtx(context.Background(), key)
+}
+
+func (p *prefixDB) DelKeyForValue(key string, value interface{}) error {
+	return p.DelKeyForValueCtx(context.Background(), key, value)
+}
+
+func (p *prefixDB) DelKeyForBytes(key string, value []byte) error {
+	return p.DelKeyForBytesCtx(context.Background(), key, value)
+}
+
+func (p *prefixDB) ReplaceValue(key string, oldValid, newValue interface{}) error {
+	return p.ReplaceValueCtx(context.Background(), key, oldValid, newValue)
+}
+
+func (p *prefixDB) CmpAndSet(cmpKey string, cmpValue interface{}, setKey string, setValue interface{}) error {
+	return p.CmpAndSetCtx(context.Background(), cmpKey, cmpValue, setKey, setValue)
+}
+
+func (p *prefixDB) CmpGTDecr(cmpKey string, greatThan int64) (int64, error) {
+	return p.CmpGTDecrCtx(context.Background(), cmpKey, greatThan)
+}
+
+func (p *prefixDB) GetLock(key string, seconds int) (string, error) {
+	return p.GetLockCtx(context.Background(), key, seconds)
+}
+
+func (p *prefixDB) DelLock(key string, lockID string) error {
+	return p.DelLockCtx(context.Background(), key, lockID)
+}
+
+func (p *prefixDB) SetLockTTL(key string, lockID string, ttl int) error {
+	return p.SetLockTTLCtx(context.Background(), key, lockID, ttl)
+}
+
+func (p *prefixDB) IncrByUint64(key string, step uint64) (uint64, error) {
+	return p.IncrByUint64Ctx(context.Background(), key, step)
+}
+
+func (p *prefixDB) IncrToUint64(key string, val uint64) (uint64, error) {
+	return p.IncrToUint64Ctx(context.Background(), key, val)
+}
+
+func (p *prefixDB) DecrByUint64(key string, step uint64) (uint64, error) {
+	return p.DecrByUint64Ctx(context.Background(), key, step)
+}
+
+func (p *prefixDB) Exists(key string) (bool, error) {
+	return p.ExistsCtx(context.Background(), key)
+}
+
+func (p *prefixDB) CacheGet(key string, v interface{}, fn FetchFunc, expires int) error {
+	return p.CacheGetCtx(context.Background(), key, v, fn, expires)
+}
+
+func (p *prefixDB) CacheGetB(key string, v interface{}, fn FetchFunc, expires int) (bool, error) {
+	return p.CacheGetBCtx(context.Background(), key, v, fn, expires)
+}
+
+func (p *prefixDB) AddSortSetStr(key string, value string, sortKey int64) error {
+	return p.AddSortSetStrCtx(context.Background(), key, value, sortKey)
+}
+
+func (p *prefixDB) GetSortSetCount(key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	return p.GetSortSetCountCtx(context.Background(), key, sortKeyFrom, sortKeyTo)
+}
+
+func (p *prefixDB) GetSortSetRangeStr(key string, sortKeyFrom, sortKeyTo int64) ([]string, error) {
+	return p.GetSortSetRangeStrCtx(context.Background(), key, sortKeyFrom, sortKeyTo)
+}
+
+func (p *prefixDB) RemoveSortSet(key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	return p.RemoveSortSetCtx(context.Background(), key, sortKeyFrom, sortKeyTo)
+}
+
+func (p *prefixDB) ZAdd(key string, members ...ZMember) (int, error) {
+	return p.ZAddCtx(context.Background(), key, members...)
+}
+
+func (p *prefixDB) ZIncrBy(key, member string, step float64) (float64, error) {
+	return p.ZIncrByCtx(context.Background(), key, member, step)
+}
+
+func (p *prefixDB) ZScore(key, member string) (float64, error) {
+	return p.ZScoreCtx(context.Background(), key, member)
+}
+
+func (p *prefixDB) ZRank(key, member string) (int, error) {
+	return p.ZRankCtx(context.Background(), key, member)
+}
+
+func (p *prefixDB) ZRevRank(key, member string) (int, error) {
+	return p.ZRevRankCtx(context.Background(), key, member)
+}
+
+func (p *prefixDB) ZRangeWithScores(key string, start, stop int) ([]ZMember, error) {
+	return p.ZRangeWithScoresCtx(context.Background(), key, start, stop)
+}
+
+func (p *prefixDB) ZRevRangeWithScores(key string, start, stop int) ([]ZMember, error) {
+	return p.ZRevRangeWithScoresCtx(context.Background(), key, start, stop)
+}
+
+func (p *prefixDB) ZRangeByScoreWithScores(key string, min, max float64, offset, count int) ([]ZMember, error) {
+	return p.ZRangeByScoreWithScoresCtx(context.Background(), key, min, max, offset, count)
+}
+
+func (p *prefixDB) ZRevRangeByScoreWithScores(key string, max, min float64, offset, count int) ([]ZMember, error) {
+	return p.ZRevRangeByScoreWithScoresCtx(context.Background(), key, max, min, offset, count)
+}
+
+func (p *prefixDB) ZRem(key string, members ...string) (int, error) {
+	return p.ZRemCtx(context.Background(), key, members...)
+}
+
+func (p *prefixDB) PushStringList(key string, value string, expires int) error {
+	return p.PushStringListCtx(context.Background(), key, value, expires)
+}
+
+func (p *prefixDB) GetStringList(key string) ([]string, error) {
+	return p.GetStringListCtx(context.Background(), key)
+}
+
+func (p *prefixDB) HSet(key, field string, value interface{}, expires int) error {
+	return p.HSetCtx(context.Background(), key, field, value, expires)
+}
+
+func (p *prefixDB) HMSet(key string, values interface{}, expires int) error {
+	return p.HMSetCtx(context.Background(), key, values, expires)
+}
+
+func (p *prefixDB) HGet(key, field string, v interface{}) error {
+	return p.HGetCtx(context.Background(), key, field, v)
+}
+
+func (p *prefixDB) HMGet(key string, fields []string, outputs []interface{}) (found []bool, err error) {
+	return p.HMGetCtx(context.Background(), key, fields, outputs)
+}
+
+func (p *prefixDB) HGetAll(key string, v interface{}) error {
+	return p.HGetAllCtx(context.Background(), key, v)
+}
+
+func (p *prefixDB) HDel(key string, fields ...string) (int, error) {
+	return p.HDelCtx(context.Background(), key, fields...)
+}
+
+func (p *prefixDB) HIncrBy(key, field string, step int64) (int64, error) {
+	return p.HIncrByCtx(context.Background(), key, field, step)
+}
+
+func (p *prefixDB) HExists(key, field string) (bool, error) {
+	return p.HExistsCtx(context.Background(), key, field)
+}
+
+func (p *prefixDB) TTL(key string) (int, error) {
+	return p.TTLCtx(context.Background(), key)
+}
+
+func (p *prefixDB) Time() (int64, error) {
+	return p.TimeCtx(context.Background())
+}
+
+func (p *prefixDB) SAdd(key string, values ...[]byte) error {
+	return p.SAddCtx(context.Background(), key, values...)
+}
+
+func (p *prefixDB) SRandMember(key string, count int) ([][]byte, error) {
+	return p.SRandMemberCtx(context.Background(), key, count)
+}
+
+func (p *prefixDB) SCard(key string) (int, error) {
+	return p.SCardCtx(context.Background(), key)
+}
+
+func (p *prefixDB) Publish(channel string, data []byte) (int, error) {
+	return p.PublishCtx(context.Background(), channel, data)
+}
+
+func (p *prefixDB) MGet(keys []string, outputs []interface{}) ([]bool, error) {
+	return p.MGetCtx(context.Background(), keys, outputs)
+}
+
+func (p *prefixDB) MSet(values map[string]interface{}, expires int) error {
+	return p.MSetCtx(context.Background(), values, expires)
+}
+
+func (p *prefixDB) DelByKeys(keyPattern string) error {
+	return p.DelByKeysCtx(context.Background(), keyPattern)
+}
+
+func (p *prefixDB) DelKeysByScan(keyPattern string) error {
+	return p.DelKeysByScanCtx(context.Background(), keyPattern)
+}
+
+func (p *prefixDB) DelKeysByScanCount(keyPattern string, count int) error {
+	return p.DelKeysByScanCountCtx(context.Background(), keyPattern, count)
+}
+
+func (p *prefixDB) ScanKeys(pattern string, count int, fn func(keys []string) error) error {
+	return p.ScanKeysCtx(context.Background(), pattern, count, fn)
+}
+
+func (p *prefixDB) SScan(key, pattern string, count int, fn func(members [][]byte) error) error {
+	return p.SScanCtx(context.Background(), key, pattern, count, fn)
+}
+
+func (p *prefixDB) ZScan(key, pattern string, count int, fn func(members []ZMember) error) error {
+	return p.ZScanCtx(context.Background(), key, pattern, count, fn)
+}
+
+func (p *prefixDB) HScan(key, pattern string, count int, fn func(fields map[string][]byte) error) error {
+	return p.HScanCtx(context.Background(), key, pattern, count, fn)
+}
+
+func (p *prefixDB) DelKeys(keys []string) error {
+	return p.DelKeysCtx(context.Background(), keys)
+}
+
+func (p *prefixDB) Subscribe(channels []string, done chan bool, recv func(name string, data []byte)) error {
+	return p.SubscribeCtx(context.Background(), channels, done, recv)
+}
+
+func (p *prefixDB) GetCtx(ctx context.Context, key string, v interface{}) error {
+	return p.cdb.GetCtx(ctx, p.key(key), v)
+}
+
+func (p *prefixDB) SetCtx(ctx context.Context, key string, value interface{}, expires int) error {
+	return p.cdb.SetCtx(ctx, p.key(key), value, expires)
+}
+
+func (p *prefixDB) SetNotExistsCtx(ctx context.Context, key string, value interface{}, expires int) error {
+	return p.cdb.SetNotExistsCtx(ctx, p.key(key), value, expires)
+}
+
+func (p *prefixDB) DelCtx(ctx context.Context, key string) error {
+	return p.cdb.DelCtx(ctx, p.key(key))
+}
+
+func (p *prefixDB) DelKeyForValueCtx(ctx context.Context, key string, value interface{}) error {
+	return p.cdb.DelKeyForValueCtx(ctx, p.key(key), value)
+}
+
+func (p *prefixDB) DelKeyForBytesCtx(ctx context.Context, key string, value []byte) error {
+	return p.cdb.DelKeyForBytesCtx(ctx, p.key(key), value)
+}
+
+func (p *prefixDB) ReplaceValueCtx(ctx context.Context, key string, oldValid, newValue interface{}) error {
+	return p.cdb.ReplaceValueCtx(ctx, p.key(key), oldValid, newValue)
+}
+
+func (p *prefixDB) CmpAndSetCtx(ctx context.Context, cmpKey string, cmpValue interface{}, setKey string, setValue interface{}) error {
+	return p.cdb.CmpAndSetCtx(ctx, p.key(cmpKey), cmpValue, p.key(setKey), setValue)
+}
+
+func (p *prefixDB) CmpGTDecrCtx(ctx context.Context, cmpKey string, greatThan int64) (int64, error) {
+	return p.cdb.CmpGTDecrCtx(ctx, p.key(cmpKey), greatThan)
+}
+
+func (p *prefixDB) GetLockCtx(ctx context.Context, key string, seconds int) (string, error) {
+	return p.cdb.GetLockCtx(ctx, p.key(key), seconds)
+}
+
+func (p *prefixDB) DelLockCtx(ctx context.Context, key string, lockID string) error {
+	return p.cdb.DelLockCtx(ctx, p.key(key), lockID)
+}
+
+func (p *prefixDB) SetLockTTLCtx(ctx context.Context, key string, lockID string, ttl int) error {
+	return p.cdb.SetLockTTLCtx(ctx, p.key(key), lockID, ttl)
+}
+
+func (p *prefixDB) IncrByUint64Ctx(ctx context.Context, key string, step uint64) (uint64, error) {
+	return p.cdb.IncrByUint64Ctx(ctx, p.key(key), step)
+}
+
+func (p *prefixDB) IncrToUint64Ctx(ctx context.Context, key string, val uint64) (uint64, error) {
+	return p.cdb.IncrToUint64Ctx(ctx, p.key(key), val)
+}
+
+func (p *prefixDB) DecrByUint64Ctx(ctx context.Context, key string, step uint64) (uint64, error) {
+	return p.cdb.DecrByUint64Ctx(ctx, p.key(key), step)
+}
+
+func (p *prefixDB) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	return p.cdb.ExistsCtx(ctx, p.key(key))
+}
+
+func (p *prefixDB) CacheGetCtx(ctx context.Context, key string, v interface{}, fn FetchFunc, expires int) error {
+	return p.cdb.CacheGetCtx(ctx, p.key(key), v, fn, expires)
+}
+
+func (p *prefixDB) CacheGetBCtx(ctx context.Context, key string, v interface{}, fn FetchFunc, expires int) (bool, error) {
+	return p.cdb.CacheGetBCtx(ctx, p.key(key), v, fn, expires)
+}
+
+func (p *prefixDB) AddSortSetStrCtx(ctx context.Context, key string, value string, sortKey int64) error {
+	return p.cdb.AddSortSetStrCtx(ctx, p.key(key), value, sortKey)
+}
+
+func (p *prefixDB) GetSortSetCountCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	return p.cdb.GetSortSetCountCtx(ctx, p.key(key), sortKeyFrom, sortKeyTo)
+}
+
+func (p *prefixDB) GetSortSetRangeStrCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) ([]string, error) {
+	return p.cdb.GetSortSetRangeStrCtx(ctx, p.key(key), sortKeyFrom, sortKeyTo)
+}
+
+func (p *prefixDB) RemoveSortSetCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	return p.cdb.RemoveSortSetCtx(ctx, p.key(key), sortKeyFrom, sortKeyTo)
+}
+
+func (p *prefixDB) ZAddCtx(ctx context.Context, key string, members ...ZMember) (int, error) {
+	return p.cdb.ZAddCtx(ctx, p.key(key), members...)
+}
+
+func (p *prefixDB) ZIncrByCtx(ctx context.Context, key, member string, step float64) (float64, error) {
+	return p.cdb.ZIncrByCtx(ctx, p.key(key), member, step)
+}
+
+func (p *prefixDB) ZScoreCtx(ctx context.Context, key, member string) (float64, error) {
+	return p.cdb.ZScoreCtx(ctx, p.key(key), member)
+}
+
+func (p *prefixDB) ZRankCtx(ctx context.Context, key, member string) (int, error) {
+	return p.cdb.ZRankCtx(ctx, p.key(key), member)
+}
+
+func (p *prefixDB) ZRevRankCtx(ctx context.Context, key, member string) (int, error) {
+	return p.cdb.ZRevRankCtx(ctx, p.key(key), member)
+}
+
+func (p *prefixDB) ZRangeWithScoresCtx(ctx context.Context, key string, start, stop int) ([]ZMember, error) {
+	return p.cdb.ZRangeWithScoresCtx(ctx, p.key(key), start, stop)
+}
+
+func (p *prefixDB) ZRevRangeWithScoresCtx(ctx context.Context, key string, start, stop int) ([]ZMember, error) {
+	return p.cdb.ZRevRangeWithScoresCtx(ctx, p.key(key), start, stop)
+}
+
+func (p *prefixDB) ZRangeByScoreWithScoresCtx(ctx context.Context, key string, min, max float64, offset, count int) ([]ZMember, error) {
+	return p.cdb.ZRangeByScoreWithScoresCtx(ctx, p.key(key), min, max, offset, count)
+}
+
+func (p *prefixDB) ZRevRangeByScoreWithScoresCtx(ctx context.Context, key string, max, min float64, offset, count int) ([]ZMember, error) {
+	return p.cdb.ZRevRangeByScoreWithScoresCtx(ctx, p.key(key), max, min, offset, count)
+}
+
+func (p *prefixDB) ZRemCtx(ctx context.Context, key string, members ...string) (int, error) {
+	return p.cdb.ZRemCtx(ctx, p.key(key), members...)
+}
+
+func (p *prefixDB) PushStringListCtx(ctx context.Context, key string, value string, expires int) error {
+	return p.cdb.PushStringListCtx(ctx, p.key(key), value, expires)
+}
+
+func (p *prefixDB) GetStringListCtx(ctx context.Context, key string) ([]string, error) {
+	return p.cdb.GetStringListCtx(ctx, p.key(key))
+}
+
+func (p *prefixDB) HSetCtx(ctx context.Context, key, field string, value interface{}, expires int) error {
+	return p.cdb.HSetCtx(ctx, p.key(key), field, value, expires)
+}
+
+func (p *prefixDB) HMSetCtx(ctx context.Context, key string, values interface{}, expires int) error {
+	return p.cdb.HMSetCtx(ctx, p.key(key), values, expires)
+}
+
+func (p *prefixDB) HGetCtx(ctx context.Context, key, field string, v interface{}) error {
+	return p.cdb.HGetCtx(ctx, p.key(key), field, v)
+}
+
+func (p *prefixDB) HMGetCtx(ctx context.Context, key string, fields []string, outputs []interface{}) (found []bool, err error) {
+	return p.cdb.HMGetCtx(ctx, p.key(key), fields, outputs)
+}
+
+func (p *prefixDB) HGetAllCtx(ctx context.Context, key string, v interface{}) error {
+	return p.cdb.HGetAllCtx(ctx, p.key(key), v)
+}
+
+func (p *prefixDB) HDelCtx(ctx context.Context, key string, fields ...string) (int, error) {
+	return p.cdb.HDelCtx(ctx, p.key(key), fields...)
+}
+
+func (p *prefixDB) HIncrByCtx(ctx context.Context, key, field string, step int64) (int64, error) {
+	return p.cdb.HIncrByCtx(ctx, p.key(key), field, step)
+}
+
+func (p *prefixDB) HExistsCtx(ctx context.Context, key, field string) (bool, error) {
+	return p.cdb.HExistsCtx(ctx, p.key(key), field)
+}
+
+func (p *prefixDB) TTLCtx(ctx context.Context, key string) (int, error) {
+	return p.cdb.TTLCtx(ctx, p.key(key))
+}
+
+func (p *prefixDB) TimeCtx(ctx context.Context) (int64, error) {
+	return p.cdb.TimeCtx(ctx)
+}
+
+func (p *prefixDB) SAddCtx(ctx context.Context, key string, values ...[]byte) error {
+	return p.cdb.SAddCtx(ctx, p.key(key), values...)
+}
+
+func (p *prefixDB) SRandMemberCtx(ctx context.Context, key string, count int) ([][]byte, error) {
+	return p.cdb.SRandMemberCtx(ctx, p.key(key), count)
+}
+
+func (p *prefixDB) SCardCtx(ctx context.Context, key string) (int, error) {
+	return p.cdb.SCardCtx(ctx, p.key(key))
+}
+
+func (p *prefixDB) PublishCtx(ctx context.Context, channel string, data []byte) (int, error) {
+	return p.cdb.PublishCtx(ctx, p.key(channel), data)
+}
+
+func (p *prefixDB) MGetCtx(ctx context.Context, keys []string, outputs []interface{}) ([]bool, error) {
+	return p.cdb.MGetCtx(ctx, p.keys(keys), outputs)
+}
+
+func (p *prefixDB) MSetCtx(ctx context.Context, values map[string]interface{}, expires int) error {
+	prefixed := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		prefixed[p.key(key)] = value
+	}
+	return p.cdb.MSetCtx(ctx, prefixed, expires)
+}
+
+func (p *prefixDB) DelByKeysCtx(ctx context.Context, keyPattern string) error {
+	return p.cdb.DelByKeysCtx(ctx, p.pattern(keyPattern))
+}
+
+func (p *prefixDB) DelKeysByScanCtx(ctx context.Context, keyPattern string) error {
+	return p.cdb.DelKeysByScanCtx(ctx, p.pattern(keyPattern))
+}
+
+func (p *prefixDB) DelKeysByScanCountCtx(ctx context.Context, keyPattern string, count int) error {
+	return p.cdb.DelKeysByScanCountCtx(ctx, p.pattern(keyPattern), count)
+}
+
+// ScanKeysCtx keys passed to fn are stripped of the prefix
+func (p *prefixDB) ScanKeysCtx(ctx context.Context, pattern string, count int, fn func(keys []string) error) error {
+	return p.cdb.ScanKeysCtx(ctx, p.pattern(pattern), count, func(keys []string) error {
+		for i := range keys {
+			keys[i] = strings.TrimPrefix(keys[i], p.prefix)
+		}
+		return fn(keys)
+	})
+}
+
+func (p *prefixDB) SScanCtx(ctx context.Context, key, pattern string, count int, fn func(members [][]byte) error) error {
+	return p.cdb.SScanCtx(ctx, p.key(key), pattern, count, fn)
+}
+
+func (p *prefixDB) ZScanCtx(ctx context.Context, key, pattern string, count int, fn func(members []ZMember) error) error {
+	return p.cdb.ZScanCtx(ctx, p.key(key), pattern, count, fn)
+}
+
+func (p *prefixDB) HScanCtx(ctx context.Context, key, pattern string, count int, fn func(fields map[string][]byte) error) error {
+	return p.cdb.HScanCtx(ctx, p.key(key), pattern, count, fn)
+}
+
+func (p *prefixDB) DelKeysCtx(ctx context.Context, keys []string) error {
+	return p.cdb.DelKeysCtx(ctx, p.keys(keys))
+}
+
+func (p *prefixDB) SubscribeCtx(ctx context.Context, channels []string, done chan bool, recv func(name string, data []byte)) error {
+	return p.cdb.SubscribeCtx(ctx, p.keys(channels), done, p.recv(recv))
+}
+
+func (p *prefixDB) NewSubscriber(recv func(channel string, data []byte), opts SubscriberOptions) Subscriber {
+	return &prefixSubscriber{p.cdb.NewSubscriber(p.recv(recv), opts), p}
+}
+
+func (p *prefixDB) Pipeline() Pipeline {
+	return &prefixPipeline{p.cdb.Pipeline(), p}
+}
+
+func (p *prefixDB) Pool() *redis.Pool {
+	return p.cdb.Pool()
+}
+
+type prefixSubscriber struct {
+	sub Subscriber
+	p   *prefixDB
+}
+
+func (s *prefixSubscriber) Subscribe(channels ...string) error {
+	return s.sub.Subscribe(s.p.keys(channels)...)
+}
+
+func (s *prefixSubscriber) Unsubscribe(channels ...string) error {
+	return s.sub.Unsubscribe(s.p.keys(channels)...)
+}
+
+func (s *prefixSubscriber) PSubscribe(patterns ...string) error {
+	prefixed := make([]string, len(patterns))
+	for i := range patterns {
+		prefixed[i] = s.p.pattern(patterns[i])
+	}
+	return s.sub.PSubscribe(prefixed...)
+}
+
+func (s *prefixSubscriber) PUnsubscribe(patterns ...string) error {
+	prefixed := make([]string, len(patterns))
+	for i := range patterns {
+		prefixed[i] = s.p.pattern(patterns[i])
+	}
+	return s.sub.PUnsubscribe(prefixed...)
+}
+
+func (s *prefixSubscriber) Close() error {
+	return s.sub.Close()
+}
+
+// prefixPipeline prefixes the keys of the typed commands, Do is sent as it is
+type prefixPipeline struct {
+	pl Pipeline
+	p  *prefixDB
+}
+
+func (pl *prefixPipeline) Get(key string, v interface{}) *PipelineCmd {
+	return pl.pl.Get(pl.p.key(key), v)
+}
+
+func (pl *prefixPipeline) Set(key string, value interface{}, expires int) *PipelineCmd {
+	return pl.pl.Set(pl.p.key(key), value, expires)
+}
+
+func (pl *prefixPipeline) Del(key string) *PipelineCmd {
+	return pl.pl.Del(pl.p.key(key))
+}
+
+func (pl *prefixPipeline) Exists(key string) *PipelineCmd {
+	return pl.pl.Exists(pl.p.key(key))
+}
+
+func (pl *prefixPipeline) IncrByUint64(key string, step uint64) *PipelineCmd {
+	return pl.pl.IncrByUint64(pl.p.key(key), step)
+}
+
+func (pl *prefixPipeline) DecrByUint64(key string, step uint64) *PipelineCmd {
+	return pl.pl.DecrByUint64(pl.p.key(key), step)
+}
+
+func (pl *prefixPipeline) TTL(key string) *PipelineCmd {
+	return pl.pl.TTL(pl.p.key(key))
+}
+
+func (pl *prefixPipeline) SAdd(key string, values ...[]byte) *PipelineCmd {
+	return pl.pl.SAdd(pl.p.key(key), values...)
+}
+
+func (pl *prefixPipeline) Publish(channel string, data []byte) *PipelineCmd {
+	return pl.pl.Publish(pl.p.key(channel), data)
+}
+
+func (pl *prefixPipeline) Do(cmd string, args ...interface{}) *PipelineCmd {
+	return pl.pl.Do(cmd, args...)
+}
+
+func (pl *prefixPipeline) Exec() error {
+	return pl.pl.Exec()
+}
+
+func (pl *prefixPipeline) ExecCtx(ctx context.Context) error {
+	return pl.pl.ExecCtx(ctx)
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixDB(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	runPrefixSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func TestMemoryPrefixDB(t *testing.T) {
+	runPrefixSuite(t, NewMemoryDB(nil))
+}
+
+func runPrefixSuite(t *testing.T, db DB) {
+	svc := WithPrefix(db, "svc:")
+	other := WithPrefix(db, "other:")
+
+	assert.NoError(t, svc.Set("k1", "v1", 0))
+	assert.NoError(t, other.Set("k1", "o1", 0))
+	val := ""
+	assert.NoError(t, db.Get("svc:k1", &val))
+	assert.Equal(t, "v1", val)
+	assert.NoError(t, svc.Get("k1", &val))
+	assert.Equal(t, "v1", val)
+	assert.Equal(t, "svc:k1", PrefixedKey(svc, "k1"))
+	assert.Equal(t, "k1", PrefixedKey(db, "k1"))
+
+	assert.NoError(t, svc.MSet(map[string]interface{}{"k2": "v2", "k3": "v3"}, 0))
+	outputs := []interface{}{new(string), new(string), new(string)}
+	found, err := svc.MGet([]string{"k1", "k2", "k4"}, outputs)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, false}, found)
+	assert.Equal(t, "v2", *outputs[1].(*string))
+
+	// both keys of CmpAndSet are prefixed
+	assert.NoError(t, svc.CmpAndSet("k1", "v1", "k5", "v5"))
+	assert.NoError(t, db.Get("svc:k5", &val))
+	assert.Equal(t, "v5", val)
+
+	// scan patterns only match keys of the prefix
+	assert.NoError(t, svc.DelKeysByScan("k*"))
+	exists, _ := db.Exists("svc:k2")
+	assert.False(t, exists)
+	exists, _ = other.Exists("k1")
+	assert.True(t, exists)
+
+	// glob characters in the prefix are escaped
+	glob := WithPrefix(db, "g*:")
+	assert.NoError(t, db.Set("gx:k1", "raw", 0))
+	assert.NoError(t, glob.Set("k1", "glob", 0))
+	assert.NoError(t, glob.DelKeysByScan("*"))
+	exists, _ = db.Exists("gx:k1")
+	assert.True(t, exists)
+	assert.True(t, errors.FindTag(glob.Get("k1", &val), errcode.ResNotFound))
+
+	lockID, err := svc.GetLock("lock", 10)
+	assert.NoError(t, err)
+	exists, _ = db.Exists("svc:lock")
+	assert.True(t, exists)
+	assert.NoError(t, svc.DelLock("lock", lockID))
+
+	pl := svc.Pipeline()
+	incr := pl.IncrByUint64("counter", 2)
+	assert.NoError(t, pl.Exec())
+	n, _ := incr.Uint64()
+	assert.Equal(t, uint64(2), n)
+	n, err = db.IncrByUint64("svc:counter", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), n)
+
+	// channels are prefixed and the prefix is stripped again for recv
+	ch := make(chan recvMessage, 10)
+	sub := svc.NewSubscriber(func(channel string, data []byte) {
+		ch <- recvMessage{channel, string(data)}
+	}, SubscriberOptions{})
+	defer sub.Close()
+	assert.NoError(t, sub.Subscribe("c1"))
+	assert.NoError(t, sub.PSubscribe("p.*"))
+
+	other.Publish("c1", []byte("other"))
+	svc.Publish("c1", []byte("1"))
+	db.Publish("svc:p.a", []byte("2"))
+	assert.Equal(t, recvMessage{"c1", "1"}, receiveOne(t, ch))
+	assert.Equal(t, recvMessage{"p.a", "2"}, receiveOne(t, ch))
+
+	cdb := AdaptContextDB(svc)
+	assert.Equal(t, svc, cdb)
+}
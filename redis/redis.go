@@ -1,60 +1,63 @@
 package redis
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"strconv"
-	"time"
 
 	"github.com/chenjie4255/tools/errcode"
 	"github.com/chenjie4255/tools/gor"
+	"github.com/chenjie4255/tools/log"
 	"github.com/chenjie4255/errors"
 	"github.com/gomodule/redigo/redis"
 )
 
-// NewPool 生成redis.Pool
-func NewPool(server, password string, db int) *redis.Pool {
-	return &redis.Pool{
-		MaxIdle:     10,
-		IdleTimeout: 240 * time.Second,
-		Dial: func() (redis.Conn, error) {
-			c, err := redis.Dial("tcp", server)
-			if err != nil {
-				return nil, err
-			}
-			if password != "" {
-				if _, err := c.Do("AUTH", password); err != nil {
-					c.Close()
-					return nil, err
-				}
-			}
-			if _, err := c.Do("SELECT", db); err != nil {
-				c.Close()
-				return nil, err
-			}
-			return c, err
-		},
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			if time.Since(t) < time.Minute {
-				return nil
-			}
-			_, err := c.Do("PING")
-			return err
-		},
-	}
+var logger *log.Logger
+
+func init() {
+	logger = log.NewLoggerWithSentry("redis")
 }
 
-func NewDB(host, password string, dbNum int) DB {
-	pool := NewPool(host, password, dbNum)
-	return &db{pool}
+// NewPool 生成redis.Pool，opts可设置TLS、ACL用户名、超时和连接池大小等
+func NewPool(server, password string, db int, opts ...PoolOption) *redis.Pool {
+	c := newPoolConfig(password, db, opts)
+	return c.newPool(func(ctx context.Context) (redis.Conn, error) {
+		return c.dial(ctx, server)
+	})
+}
+
+func NewDB(host, password string, dbNum int, opts ...PoolOption) DB {
+	pool := NewPool(host, password, dbNum, opts...)
+	return &db{pool: pool, codec: JSONCodec}
 }
 
 func NewDBFromPool(pool *redis.Pool) DB {
-	return &db{pool}
+	return &db{pool: pool, codec: JSONCodec}
+}
+
+// NewDBWithCodec 生成使用指定Codec编码value的DB
+func NewDBWithCodec(pool *redis.Pool, codec Codec) DB {
+	return &db{pool: pool, codec: codec}
+}
+
+// NewContextDB 生成支持context的DB
+func NewContextDB(host, password string, dbNum int, opts ...PoolOption) ContextDB {
+	pool := NewPool(host, password, dbNum, opts...)
+	return &db{pool: pool, codec: JSONCodec}
 }
 
-func FlushDB(host, password string, dbNum int) error {
-	pool := NewPool(host, password, dbNum)
+func NewContextDBFromPool(pool *redis.Pool) ContextDB {
+	return &db{pool: pool, codec: JSONCodec}
+}
+
+func NewContextDBWithCodec(pool *redis.Pool, codec Codec) ContextDB {
+	return &db{pool: pool, codec: codec}
+}
+
+func FlushDB(host, password string, dbNum int, opts ...PoolOption) error {
+	pool := NewPool(host, password, dbNum, opts...)
+	defer pool.Close()
+
 	conn := pool.Get()
 	defer conn.Close()
 	_, err := conn.Do("FLUSHDB")
@@ -62,14 +65,34 @@ func FlushDB(host, password string, dbNum int) error {
 }
 
 type db struct {
-	pool *redis.Pool
+	pool  *redis.Pool
+	codec Codec
+	hooks []Hook
+}
+
+// do runs one command on a pooled connection, ctx bounds both borrowing and the command
+func (d *db) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := d.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.DoContext(conn, ctx, cmd, args...)
 }
 
-func (d *db) Get(key string, v interface{}) error {
-	conn := d.pool.Get()
+func (d *db) doScript(ctx context.Context, s *Script, keysAndArgs ...interface{}) (interface{}, error) {
+	conn, err := d.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
 	defer conn.Close()
 
-	data, err := redis.Bytes(conn.Do("GET", key))
+	return s.scr.DoContext(ctx, conn, keysAndArgs...)
+}
+
+func (d *db) GetCtx(ctx context.Context, key string, v interface{}) error {
+	data, err := redis.Bytes(d.do(ctx, "GET", key))
 	if err != nil {
 		if err == redis.ErrNil {
 			return errors.NewWithTag("key doesn't exists", errcode.ResNotFound)
@@ -77,22 +100,20 @@ func (d *db) Get(key string, v interface{}) error {
 		return err
 	}
 
-	return json.Unmarshal(data, &v)
+	return d.codec.Unmarshal(data, v)
 }
 
-func (d *db) Set(key string, value interface{}, expires int) error {
-	data, err := json.Marshal(value)
+func (d *db) SetCtx(ctx context.Context, key string, value interface{}, expires int) error {
+	data, err := d.codec.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	return d.set(key, data, expires)
+	return d.set(ctx, key, data, expires)
 }
 
-func (d *db) Exists(key string) (bool, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-	result, err := redis.Int(conn.Do("EXISTS", key))
+func (d *db) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	result, err := redis.Int(d.do(ctx, "EXISTS", key))
 	if err != nil {
 		return false, err
 	}
@@ -106,20 +127,17 @@ else
 return 0
 end`
 
-func (d *db) setNotExists(key string, value interface{}, expires int) error {
+func (d *db) setNotExists(ctx context.Context, key string, value interface{}, expires int) error {
 	if expires <= 0 {
 		panic("expires shoudl be greater than zero")
 	}
 
-	data, err := json.Marshal(value)
+	data, err := d.codec.Marshal(value)
 	if err != nil {
 		return err
 	}
-	scr := redis.NewScript(1, setNotExistsScript)
-	conn := d.pool.Get()
-	defer conn.Close()
 
-	ret, err := redis.Int(scr.Do(conn, key, data, expires))
+	ret, err := redis.Int(d.doScript(ctx, setNotExistsScr, key, data, expires))
 	if err != nil {
 		return err
 	}
@@ -131,19 +149,17 @@ func (d *db) setNotExists(key string, value interface{}, expires int) error {
 	return nil
 }
 
-func (d *db) SetNotExists(key string, value interface{}, expires int) error {
+func (d *db) SetNotExistsCtx(ctx context.Context, key string, value interface{}, expires int) error {
 	if expires > 0 {
-		return d.setNotExists(key, value, expires)
+		return d.setNotExists(ctx, key, value, expires)
 	}
 
-	data, err := json.Marshal(value)
+	data, err := d.codec.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	conn := d.pool.Get()
-	defer conn.Close()
-	result, err := redis.Int(conn.Do("SETNX", key, data))
+	result, err := redis.Int(d.do(ctx, "SETNX", key, data))
 	if err != nil {
 		return err
 	}
@@ -155,87 +171,29 @@ func (d *db) SetNotExists(key string, value interface{}, expires int) error {
 	return nil
 }
 
-func (d *db) set(key string, data []byte, expires int) error {
-	conn := d.pool.Get()
-	defer conn.Close()
+func (d *db) set(ctx context.Context, key string, data []byte, expires int) error {
 	if expires > 0 {
-		_, err := conn.Do("SET", key, data, "EX", expires)
+		_, err := d.do(ctx, "SET", key, data, "EX", expires)
 		return err
 	}
 
-	_, err := conn.Do("SET", key, data)
+	_, err := d.do(ctx, "SET", key, data)
 	return err
 
 }
 
-func (d *db) Del(key string) error {
-	conn := d.pool.Get()
-	defer conn.Close()
-	_, err := conn.Do("DEL", key)
+func (d *db) DelCtx(ctx context.Context, key string) error {
+	_, err := d.do(ctx, "DEL", key)
 	return err
 }
 
-func (d *db) DelByKeys(keys string) error {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	items, err := redis.Strings(conn.Do("KEYS", keys))
-	if err != nil {
-		return err
-	}
-
-	ifaces := []interface{}{}
-	for _, item := range items {
-		ifaces = append(ifaces, item)
-	}
-
-	_, err = conn.Do("DEL", ifaces...)
-	return err
-}
-
-func (d *db) DelKeysByScan(keyPattern string) error {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	iter := 0
-	var keys []string
-	for {
-		if arr, err := redis.Values(conn.Do("SCAN", iter, "MATCH", keyPattern)); err != nil {
-			return err
-		} else {
-			iter, _ = redis.Int(arr[0], nil)
-			keys, _ = redis.Strings(arr[1], nil)
-		}
-
-		delKeys := []interface{}{}
-		for i := range keys {
-			delKeys = append(delKeys, keys[i])
-		}
-
-		if len(delKeys) > 0 {
-			if _, err := conn.Do("DEL", delKeys...); err != nil {
-				return err
-			}
-		}
-
-		if iter == 0 {
-			break
-		}
-	}
-
-	return nil
-}
-
-func (d *db) DelKeys(keys []string) error {
-	conn := d.pool.Get()
-	defer conn.Close()
-
+func (d *db) DelKeysCtx(ctx context.Context, keys []string) error {
 	ifaces := []interface{}{}
 	for _, item := range keys {
 		ifaces = append(ifaces, item)
 	}
 
-	_, err := conn.Do("DEL", ifaces...)
+	_, err := d.do(ctx, "DEL", ifaces...)
 	return err
 }
 
@@ -245,21 +203,17 @@ else
 return 0
 end`
 
-func (d *db) DelKeyForValue(key string, value interface{}) error {
-	jsonData, err := json.Marshal(value)
+func (d *db) DelKeyForValueCtx(ctx context.Context, key string, value interface{}) error {
+	data, err := d.codec.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	return d.DelKeyForBytes(key, jsonData)
+	return d.DelKeyForBytesCtx(ctx, key, data)
 }
 
-func (d *db) DelKeyForBytes(key string, value []byte) error {
-	scr := redis.NewScript(1, delKeyValueScript)
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	ret, err := redis.Int(scr.Do(conn, key, value))
+func (d *db) DelKeyForBytesCtx(ctx context.Context, key string, value []byte) error {
+	ret, err := redis.Int(d.doScript(ctx, DelIfEqualScript, key, value))
 	if err != nil {
 		return err
 	}
@@ -277,22 +231,18 @@ else
 return "NOOK"
 end`
 
-func (d *db) ReplaceValue(key string, oldValue, newValue interface{}) error {
-	oldData, err := json.Marshal(oldValue)
+func (d *db) ReplaceValueCtx(ctx context.Context, key string, oldValue, newValue interface{}) error {
+	oldData, err := d.codec.Marshal(oldValue)
 	if err != nil {
 		return err
 	}
 
-	newData, err := json.Marshal(newValue)
+	newData, err := d.codec.Marshal(newValue)
 	if err != nil {
 		return err
 	}
 
-	scr := redis.NewScript(1, replaceValueScript)
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	ret, err := redis.String(scr.Do(conn, key, oldData, newData))
+	ret, err := redis.String(d.doScript(ctx, replaceValueScr, key, oldData, newData))
 	if err != nil {
 		return err
 	}
@@ -310,22 +260,18 @@ else
 return "NOOK"
 end`
 
-func (d *db) CmpAndSet(cmpKey string, cmpValue interface{}, setKey string, setValue interface{}) error {
-	cmpData, err := json.Marshal(cmpValue)
+func (d *db) CmpAndSetCtx(ctx context.Context, cmpKey string, cmpValue interface{}, setKey string, setValue interface{}) error {
+	cmpData, err := d.codec.Marshal(cmpValue)
 	if err != nil {
 		return err
 	}
 
-	setData, err := json.Marshal(setValue)
+	setData, err := d.codec.Marshal(setValue)
 	if err != nil {
 		return err
 	}
 
-	scr := redis.NewScript(2, cmpSetScript)
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	ret, err := redis.String(scr.Do(conn, cmpKey, setKey, cmpData, setData))
+	ret, err := redis.String(d.doScript(ctx, cmpSetScr, cmpKey, setKey, cmpData, setData))
 	if err != nil {
 		return err
 	}
@@ -343,35 +289,18 @@ else
 return redis.error_reply("not exists or unmatch")
 end`
 
-func (d *db) CmpGTDecr(cmpKey string, greatThan int64) (int64, error) {
-	scr := redis.NewScript(1, cmpGTDecrScript)
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	return redis.Int64(scr.Do(conn, cmpKey, greatThan))
+func (d *db) CmpGTDecrCtx(ctx context.Context, cmpKey string, greatThan int64) (int64, error) {
+	return redis.Int64(d.doScript(ctx, cmpGTDecrScr, cmpKey, greatThan))
 }
 
-func (d *db) CacheGet(key string, v interface{}, fn FetchFunc, expires int) error {
-	if err := d.Get(key, v); err == nil {
-		// cache hit
-		return nil
-	}
-
-	data, err := fn()
-	if err != nil {
-		return err
-	}
-
-	rawData, _ := json.Marshal(data)
-	gor.RunWithRecover(func() {
-		d.set(key, rawData, expires)
-	})
-
-	return json.Unmarshal(rawData, v)
+// CacheGetCtx writes the fetched value back in background, the write is not bound to ctx
+func (d *db) CacheGetCtx(ctx context.Context, key string, v interface{}, fn FetchFunc, expires int) error {
+	_, err := d.CacheGetBCtx(ctx, key, v, fn, expires)
+	return err
 }
 
-func (d *db) CacheGetB(key string, v interface{}, fn FetchFunc, expires int) (bool, error) {
-	if err := d.Get(key, v); err == nil {
+func (d *db) CacheGetBCtx(ctx context.Context, key string, v interface{}, fn FetchFunc, expires int) (bool, error) {
+	if err := d.GetCtx(ctx, key, v); err == nil {
 		// cache hit
 		return true, nil
 	}
@@ -381,19 +310,16 @@ func (d *db) CacheGetB(key string, v interface{}, fn FetchFunc, expires int) (bo
 		return false, err
 	}
 
-	rawData, _ := json.Marshal(data)
+	rawData, _ := d.codec.Marshal(data)
 	gor.RunWithRecover(func() {
-		d.set(key, rawData, expires)
+		d.set(context.Background(), key, rawData, expires)
 	})
 
-	return false, json.Unmarshal(rawData, v)
+	return false, d.codec.Unmarshal(rawData, v)
 }
 
-func (d *db) AddSortSetStr(key string, value string, sortKey int64) error {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	result, err := redis.Int(conn.Do("ZADD", key, sortKey, value))
+func (d *db) AddSortSetStrCtx(ctx context.Context, key string, value string, sortKey int64) error {
+	result, err := redis.Int(d.do(ctx, "ZADD", key, sortKey, value))
 	if err != nil {
 		return err
 	}
@@ -405,37 +331,31 @@ func (d *db) AddSortSetStr(key string, value string, sortKey int64) error {
 	return nil
 }
 
-func (d *db) GetSortSetCount(key string, sortKeyFrom, sortKeyTo int64) (int, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	return redis.Int(conn.Do("ZCOUNT", key, sortKeyFrom, sortKeyTo))
+func (d *db) GetSortSetCountCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	return redis.Int(d.do(ctx, "ZCOUNT", key, sortKeyFrom, sortKeyTo))
 }
 
-func (d *db) GetSortSetRangeStr(key string, sortKeyFrom, sortKeyTo int64) ([]string, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	return redis.Strings(conn.Do("ZRANGEBYSCORE", key, sortKeyFrom, sortKeyTo))
+func (d *db) GetSortSetRangeStrCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) ([]string, error) {
+	return redis.Strings(d.do(ctx, "ZRANGEBYSCORE", key, sortKeyFrom, sortKeyTo))
 }
 
-func (d *db) RemoveSortSet(key string, sortKeyFrom, sortKeyTo int64) (int, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	return redis.Int(conn.Do("ZREMRANGEBYSCORE", key, sortKeyFrom, sortKeyTo))
+func (d *db) RemoveSortSetCtx(ctx context.Context, key string, sortKeyFrom, sortKeyTo int64) (int, error) {
+	return redis.Int(d.do(ctx, "ZREMRANGEBYSCORE", key, sortKeyFrom, sortKeyTo))
 }
 
-func (d *db) PushStringList(key string, value string, expires int) error {
-	conn := d.pool.Get()
+func (d *db) PushStringListCtx(ctx context.Context, key string, value string, expires int) error {
+	conn, err := d.getConn(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
-	_, err := conn.Do("LPUSH", key, value)
+	_, err = redis.DoContext(conn, ctx, "LPUSH", key, value)
 	if err != nil {
 		return err
 	}
 
-	result, err := redis.Int(conn.Do("EXPIRE", key, expires))
+	result, err := redis.Int(redis.DoContext(conn, ctx, "EXPIRE", key, expires))
 	if err != nil {
 		return err
 	}
@@ -447,18 +367,12 @@ func (d *db) PushStringList(key string, value string, expires int) error {
 	return nil
 }
 
-func (d *db) GetStringList(key string) ([]string, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	return redis.Strings(conn.Do("LRANGE", key, 0, -1))
+func (d *db) GetStringListCtx(ctx context.Context, key string) ([]string, error) {
+	return redis.Strings(d.do(ctx, "LRANGE", key, 0, -1))
 }
 
-func (d *db) TTL(key string) (int, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	result, err := redis.Int(conn.Do("TTL", key))
+func (d *db) TTLCtx(ctx context.Context, key string) (int, error) {
+	result, err := redis.Int(d.do(ctx, "TTL", key))
 	if err != nil {
 		return 0, err
 	}
@@ -470,11 +384,8 @@ func (d *db) TTL(key string) (int, error) {
 	return result, nil
 }
 
-func (d *db) IncrByUint64(key string, step uint64) (uint64, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	result, err := redis.Uint64(conn.Do("INCRBY", key, step))
+func (d *db) IncrByUint64Ctx(ctx context.Context, key string, step uint64) (uint64, error) {
+	result, err := redis.Uint64(d.do(ctx, "INCRBY", key, step))
 	if err != nil {
 		return 0, err
 	}
@@ -482,11 +393,8 @@ func (d *db) IncrByUint64(key string, step uint64) (uint64, error) {
 	return result, err
 }
 
-func (d *db) DecrByUint64(key string, step uint64) (uint64, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	result, err := redis.Uint64(conn.Do("DECRBY", key, step))
+func (d *db) DecrByUint64Ctx(ctx context.Context, key string, step uint64) (uint64, error) {
+	result, err := redis.Uint64(d.do(ctx, "DECRBY", key, step))
 	if err != nil {
 		return 0, err
 	}
@@ -495,10 +403,7 @@ func (d *db) DecrByUint64(key string, step uint64) (uint64, error) {
 }
 
 func (d *db) GetSetUInt64(key string, val uint64) (uint64, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	result, err := redis.Uint64(conn.Do("GETSET", key, val))
+	result, err := redis.Uint64(d.do(context.Background(), "GETSET", key, val))
 	if err != nil {
 		return 0, err
 	}
@@ -512,12 +417,8 @@ else
 return "NOOK"
 end`
 
-func (d *db) IncrToUint64(key string, val uint64) (uint64, error) {
-	scr := redis.NewScript(1, incrToScript)
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	ret, err := redis.String(scr.Do(conn, key, val))
+func (d *db) IncrToUint64Ctx(ctx context.Context, key string, val uint64) (uint64, error) {
+	ret, err := redis.String(d.doScript(ctx, incrToScr, key, val))
 	if err != nil {
 		return 0, err
 	}
@@ -529,11 +430,8 @@ func (d *db) IncrToUint64(key string, val uint64) (uint64, error) {
 	return val, nil
 }
 
-func (d *db) Time() (int64, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	ret, err := redis.Strings(conn.Do("TIME"))
+func (d *db) TimeCtx(ctx context.Context) (int64, error) {
+	ret, err := redis.Strings(d.do(ctx, "TIME"))
 	if err != nil {
 		return 0, err
 	}
@@ -546,9 +444,17 @@ func (d *db) Time() (int64, error) {
 }
 
 func MarshalJSONSlice(ifs []interface{}) ([][]byte, error) {
+	return marshalSlice(JSONCodec, ifs)
+}
+
+func UnMarshalJSONSlice(data [][]byte, output []interface{}) error {
+	return unmarshalSlice(JSONCodec, data, output)
+}
+
+func marshalSlice(codec Codec, ifs []interface{}) ([][]byte, error) {
 	ret := [][]byte{}
 	for i := range ifs {
-		data, err := json.Marshal(ifs[i])
+		data, err := codec.Marshal(ifs[i])
 		if err != nil {
 			return nil, err
 		}
@@ -558,13 +464,13 @@ func MarshalJSONSlice(ifs []interface{}) ([][]byte, error) {
 	return ret, nil
 }
 
-func UnMarshalJSONSlice(data [][]byte, output []interface{}) error {
+func unmarshalSlice(codec Codec, data [][]byte, output []interface{}) error {
 	if len(data) != len(output) {
 		return fmt.Errorf("the lenght of data and output is not equal %d != %d", len(data), len(output))
 	}
 
 	for i := range data {
-		err := json.Unmarshal(data[i], output[i])
+		err := codec.Unmarshal(data[i], output[i])
 		if err != nil {
 			return err
 		}
@@ -573,35 +479,117 @@ func UnMarshalJSONSlice(data [][]byte, output []interface{}) error {
 	return nil
 }
 
-func (d *db) SAdd(key string, values ...[]byte) error {
-	conn := d.pool.Get()
+// MGetCtx 批量获取，found[i]为false时outputs[i]保持不变
+func (d *db) MGetCtx(ctx context.Context, keys []string, outputs []interface{}) ([]bool, error) {
+	if len(keys) != len(outputs) {
+		return nil, fmt.Errorf("the lenght of keys and outputs is not equal %d != %d", len(keys), len(outputs))
+	}
+	if len(keys) == 0 {
+		return []bool{}, nil
+	}
+
+	args := []interface{}{}
+	for i := range keys {
+		args = append(args, keys[i])
+	}
+
+	values, err := redis.Values(d.do(ctx, "MGET", args...))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMGet(d.codec, values, outputs)
+}
+
+// decodeMGet decodes the non-nil replies of MGET into outputs
+func decodeMGet(codec Codec, values []interface{}, outputs []interface{}) ([]bool, error) {
+	found := make([]bool, len(values))
+	data := [][]byte{}
+	foundOutputs := []interface{}{}
+	for i := range values {
+		if values[i] == nil {
+			continue
+		}
+
+		item, err := redis.Bytes(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		found[i] = true
+		data = append(data, item)
+		foundOutputs = append(foundOutputs, outputs[i])
+	}
+
+	if err := unmarshalSlice(codec, data, foundOutputs); err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// MSetCtx 批量写入，expires大于0时在同一个事务中设置过期时间
+func (d *db) MSetCtx(ctx context.Context, values map[string]interface{}, expires int) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	args := []interface{}{}
+	for key, value := range values {
+		data, err := d.codec.Marshal(value)
+		if err != nil {
+			return err
+		}
+		args = append(args, key, data)
+	}
+
+	if expires <= 0 {
+		_, err := d.do(ctx, "MSET", args...)
+		return err
+	}
+
+	conn, err := d.getConn(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	for i := 0; i < len(args); i += 2 {
+		if err := conn.Send("SET", args[i], args[i+1], "EX", expires); err != nil {
+			return err
+		}
+	}
+
+	_, err = redis.DoContext(conn, ctx, "EXEC")
+	return err
+}
+
+func (d *db) SAddCtx(ctx context.Context, key string, values ...[]byte) error {
 	params := []interface{}{key}
 	for i := range values {
 		params = append(params, values[i])
 	}
 
-	_, err := redis.Int(conn.Do("SADD", params...))
+	_, err := redis.Int(d.do(ctx, "SADD", params...))
 	return err
 }
 
-func (d *db) SRandMember(key string, count int) ([][]byte, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	return redis.ByteSlices(conn.Do("SRANDMEMBER", key, count))
+func (d *db) SRandMemberCtx(ctx context.Context, key string, count int) ([][]byte, error) {
+	return redis.ByteSlices(d.do(ctx, "SRANDMEMBER", key, count))
 }
 
-func (d *db) SCard(key string) (int, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	return redis.Int(conn.Do("SCARD", key))
+func (d *db) SCardCtx(ctx context.Context, key string) (int, error) {
+	return redis.Int(d.do(ctx, "SCARD", key))
 }
 
-func (d *db) Subscribe(channels []string, done chan bool, recv func(name string, data []byte)) error {
-	conn := d.pool.Get()
+// SubscribeCtx blocks until ctx is done or the connection fails
+func (d *db) SubscribeCtx(ctx context.Context, channels []string, done chan bool, recv func(name string, data []byte)) error {
+	conn, err := d.getConn(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close()
 	pcs := redis.PubSubConn{
 		Conn: conn,
@@ -619,15 +607,16 @@ func (d *db) Subscribe(channels []string, done chan bool, recv func(name string,
 	subCount := 0
 
 	for {
-		switch v := pcs.Receive().(type) {
+		switch v := pcs.ReceiveContext(ctx).(type) {
 		case redis.Message:
-			fmt.Printf("Message %s:\n", v.Channel)
 			recv(v.Channel, v.Data)
 		case error:
-			fmt.Printf("error %s:\n", v)
+			logger.AddFile().WithFields(log.Fields{
+				"channels": channels,
+				"error":    v,
+			}).Warn("subscription is broken")
 			return v
 		case redis.Subscription:
-			fmt.Printf("Subscription %s: %s %d\n", v.Channel, v.Kind, v.Count)
 			subCount++
 			if subCount == len(channels) {
 				done <- true
@@ -636,10 +625,7 @@ func (d *db) Subscribe(channels []string, done chan bool, recv func(name string,
 	}
 }
 
-func (d *db) Publish(channel string, data []byte) (int, error) {
-	conn := d.pool.Get()
-	defer conn.Close()
-
-	count, err := redis.Int(conn.Do("PUBLISH", channel, data))
+func (d *db) PublishCtx(ctx context.Context, channel string, data []byte) (int, error) {
+	count, err := redis.Int(d.do(ctx, "PUBLISH", channel, data))
 	return count, err
 }
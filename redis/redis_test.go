@@ -22,14 +22,20 @@ func TestCacheOp(t *testing.T) {
 		t.Skip("env not configured yet, skip this test")
 	}
 
+	runCacheOpSuite(t, func() DB {
+		FlushDB(env.RedisHost, "", 3)
+		return NewDB(env.RedisHost, "", 3)
+	}, time.Sleep)
+}
+
+func runCacheOpSuite(t *testing.T, newDB func() DB, sleep func(time.Duration)) {
 	type Item struct {
 		Name  string  `json:"name"`
 		Price float64 `json:"price"`
 	}
 
 	Convey("create cache", t, func() {
-		FlushDB(env.RedisHost, "", 3)
-		cache := NewDB(env.RedisHost, "", 3)
+		cache := newDB()
 
 		Convey("test decr", func() {
 			So(cache.Set("kk1", 100, 1000), ShouldBeNil)
@@ -194,7 +200,7 @@ func TestCacheOp(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(check, ShouldEqual, 100)
 
-			time.Sleep(1 * time.Second)
+			sleep(1 * time.Second)
 
 			check2 := 0
 			err = cache.CacheGet("a", &check2, getFn, 100)
@@ -209,7 +215,7 @@ func TestCacheOp(t *testing.T) {
 			err = cache.SetNotExists("k1", nil, 100)
 			So(err, ShouldNotBeNil)
 
-			time.Sleep(2 * time.Second)
+			sleep(2 * time.Second)
 
 			err = cache.SetNotExists("k1", nil, 100)
 			So(err, ShouldBeNil)
@@ -278,7 +284,7 @@ func TestCacheOp(t *testing.T) {
 			So(err, ShouldBeNil)
 			So(ttl, ShouldBeGreaterThan, 98)
 
-			time.Sleep(2 * time.Second)
+			sleep(2 * time.Second)
 			ttl, err = cache.TTL("k1")
 			So(err, ShouldBeNil)
 			So(ttl, ShouldBeLessThan, 100)
@@ -360,14 +366,20 @@ func TestSliceOp(t *testing.T) {
 		t.Skip("env not configured yet, skip this test")
 	}
 
+	runSliceOpSuite(t, func() DB {
+		FlushDB(env.RedisHost, "", 3)
+		return NewDB(env.RedisHost, "", 3)
+	})
+}
+
+func runSliceOpSuite(t *testing.T, newDB func() DB) {
 	type Item struct {
 		Name  string  `json:"name"`
 		Price float64 `json:"price"`
 	}
 
 	Convey("create cache", t, func() {
-		FlushDB(env.RedisHost, "", 3)
-		db := NewDB(env.RedisHost, "", 3)
+		db := newDB()
 
 		item1 := Item{Name: "1", Price: 1.2}
 		item2 := Item{Name: "2", Price: 1.3}
@@ -409,13 +421,11 @@ func TestPubSub(t *testing.T) {
 		t.Skip("env not configured yet, skip this test")
 	}
 
-	type Item struct {
-		Name  string  `json:"name"`
-		Price float64 `json:"price"`
-	}
-
 	FlushDB(env.RedisHost, "", 3)
-	db := NewDB(env.RedisHost, "", 3)
+	runPubSubSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func runPubSubSuite(t *testing.T, db DB) {
 
 	sendData := "abc"
 
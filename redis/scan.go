@@ -0,0 +1,136 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/chenjie4255/errors"
+	"github.com/gomodule/redigo/redis"
+)
+
+// defaultScanCount is the COUNT hint used when count is not greater than 0
+const defaultScanCount = 100
+
+func scanCount(count int) int {
+	if count <= 0 {
+		return defaultScanCount
+	}
+	return count
+}
+
+// scan runs SCAN, or SSCAN/ZSCAN/HSCAN on key, until the cursor returns to 0 and calls fn
+// with every non-empty batch. The same connection is used for the whole iteration.
+func (d *db) scan(ctx context.Context, cmd, key, pattern string, count int, fn func(values []interface{}) error) error {
+	conn, err := d.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	cursor := "0"
+	for {
+		args := []interface{}{}
+		if cmd != "SCAN" {
+			args = append(args, key)
+		}
+		args = append(args, cursor)
+		if pattern != "" {
+			args = append(args, "MATCH", pattern)
+		}
+		args = append(args, "COUNT", scanCount(count))
+
+		reply, err := redis.Values(redis.DoContext(conn, ctx, cmd, args...))
+		if err != nil {
+			return err
+		}
+		if len(reply) != 2 {
+			return errors.Errorf("unexpected %s reply", cmd)
+		}
+
+		cursor, _ = redis.String(reply[0], nil)
+		values, _ := redis.Values(reply[1], nil)
+		if len(values) > 0 {
+			if err := fn(values); err != nil {
+				return err
+			}
+		}
+
+		if cursor == "0" {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *db) ScanKeysCtx(ctx context.Context, pattern string, count int, fn func(keys []string) error) error {
+	return d.scan(ctx, "SCAN", "", pattern, count, func(values []interface{}) error {
+		keys, err := redis.Strings(values, nil)
+		if err != nil {
+			return err
+		}
+		return fn(keys)
+	})
+}
+
+func (d *db) SScanCtx(ctx context.Context, key, pattern string, count int, fn func(members [][]byte) error) error {
+	return d.scan(ctx, "SSCAN", key, pattern, count, func(values []interface{}) error {
+		members, err := redis.ByteSlices(values, nil)
+		if err != nil {
+			return err
+		}
+		return fn(members)
+	})
+}
+
+func (d *db) ZScanCtx(ctx context.Context, key, pattern string, count int, fn func(members []ZMember) error) error {
+	return d.scan(ctx, "ZSCAN", key, pattern, count, func(values []interface{}) error {
+		members, err := zMembers(values, nil)
+		if err != nil {
+			return err
+		}
+		return fn(members)
+	})
+}
+
+func (d *db) HScanCtx(ctx context.Context, key, pattern string, count int, fn func(fields map[string][]byte) error) error {
+	return d.scan(ctx, "HSCAN", key, pattern, count, func(values []interface{}) error {
+		if len(values)%2 != 0 {
+			return errors.New("unexpected HSCAN reply")
+		}
+
+		fields := make(map[string][]byte, len(values)/2)
+		for i := 0; i < len(values); i += 2 {
+			name, err := redis.String(values[i], nil)
+			if err != nil {
+				return err
+			}
+			data, err := redis.Bytes(values[i+1], nil)
+			if err != nil {
+				return err
+			}
+			fields[name] = data
+		}
+		return fn(fields)
+	})
+}
+
+func (d *db) DelKeysByScanCountCtx(ctx context.Context, keyPattern string, count int) error {
+	return d.ScanKeysCtx(ctx, keyPattern, count, func(keys []string) error {
+		args := make([]interface{}, len(keys))
+		for i := range keys {
+			args[i] = keys[i]
+		}
+		_, err := d.do(ctx, "UNLINK", args...)
+		return err
+	})
+}
+
+func (d *db) DelKeysByScanCtx(ctx context.Context, keyPattern string) error {
+	return d.DelKeysByScanCountCtx(ctx, keyPattern, defaultScanCount)
+}
+
+// DelByKeysCtx is the same as DelKeysByScanCtx, KEYS blocks the server
+func (d *db) DelByKeysCtx(ctx context.Context, keyPattern string) error {
+	return d.DelKeysByScanCtx(ctx, keyPattern)
+}
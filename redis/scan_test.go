@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScan(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	runScanSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func TestMemoryScan(t *testing.T) {
+	runScanSuite(t, NewMemoryDB(nil))
+}
+
+func runScanSuite(t *testing.T, db DB) {
+	for i := 0; i < 25; i++ {
+		assert.NoError(t, db.Set(fmt.Sprintf("scan_%02d", i), i, 0))
+	}
+	assert.NoError(t, db.Set("other", 1, 0))
+
+	// keys may be returned more than once
+	seen := map[string]bool{}
+	assert.NoError(t, db.ScanKeys("scan_*", 10, func(keys []string) error {
+		for _, key := range keys {
+			seen[key] = true
+		}
+		return nil
+	}))
+	assert.Equal(t, 25, len(seen))
+
+	// the iteration stops with the error of fn
+	stop := errors.New("stop")
+	batches := 0
+	err := db.ScanKeys("scan_*", 5, func(keys []string) error {
+		batches++
+		return stop
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 1, batches)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = AdaptContextDB(db).ScanKeysCtx(ctx, "scan_*", 5, func(keys []string) error {
+		return nil
+	})
+	assert.Equal(t, context.Canceled, errors.Cause(err))
+
+	assert.NoError(t, db.SAdd("scan_set", []byte("a1"), []byte("a2"), []byte("b1")))
+	members := []string{}
+	assert.NoError(t, db.SScan("scan_set", "a*", 0, func(batch [][]byte) error {
+		for _, member := range batch {
+			members = append(members, string(member))
+		}
+		return nil
+	}))
+	sort.Strings(members)
+	assert.Equal(t, []string{"a1", "a2"}, members)
+
+	_, err = db.ZAdd("scan_zset", ZMember{"m1", 1}, ZMember{"m2", 2}, ZMember{"n1", 3})
+	assert.NoError(t, err)
+	zmembers := map[string]float64{}
+	assert.NoError(t, db.ZScan("scan_zset", "m*", 0, func(batch []ZMember) error {
+		for _, zm := range batch {
+			zmembers[zm.Member] = zm.Score
+		}
+		return nil
+	}))
+	assert.Equal(t, map[string]float64{"m1": 1, "m2": 2}, zmembers)
+
+	assert.NoError(t, db.HMSet("scan_hash", map[string]int{"f1": 1, "f2": 2, "g1": 3}, 0))
+	fields := map[string]int{}
+	assert.NoError(t, db.HScan("scan_hash", "f*", 0, func(batch map[string][]byte) error {
+		for name, data := range batch {
+			v := 0
+			assert.NoError(t, JSONCodec.Unmarshal(data, &v))
+			fields[name] = v
+		}
+		return nil
+	}))
+	assert.Equal(t, map[string]int{"f1": 1, "f2": 2}, fields)
+
+	// scanning a key of another type fails
+	assert.Error(t, db.SScan("scan_hash", "", 0, func([][]byte) error { return nil }))
+
+	assert.NoError(t, db.DelKeysByScanCount("scan_*", 3))
+	count := 0
+	assert.NoError(t, db.ScanKeys("", 0, func(keys []string) error {
+		count += len(keys)
+		return nil
+	}))
+	assert.Equal(t, 1, count)
+
+	// nothing matches
+	assert.NoError(t, db.DelByKeys("scan_*"))
+}
@@ -0,0 +1,238 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrScriptNotSupported 不支持lua脚本的DB，如内存DB
+var ErrScriptNotSupported = errors.New("lua scripts require a redis backed DB")
+
+// Script 注册过的lua脚本，执行时使用EVALSHA，服务器没有缓存脚本时自动改用EVAL
+type Script struct {
+	name     string
+	keyCount int
+	src      string
+	scr      *redis.Script
+}
+
+// Name 注册时的名字
+func (s *Script) Name() string {
+	return s.name
+}
+
+// Hash 脚本的SHA1
+func (s *Script) Hash() string {
+	return s.scr.Hash()
+}
+
+// ScriptArgs 脚本的KEYS和ARGV
+type ScriptArgs struct {
+	keys []string
+	argv []interface{}
+}
+
+// NewScriptArgs 生成以keys为KEYS的参数，ARGV由String、Int等方法依次追加
+func NewScriptArgs(keys ...string) *ScriptArgs {
+	return &ScriptArgs{keys: keys}
+}
+
+func (a *ScriptArgs) String(v string) *ScriptArgs {
+	a.argv = append(a.argv, v)
+	return a
+}
+
+func (a *ScriptArgs) Bytes(v []byte) *ScriptArgs {
+	a.argv = append(a.argv, v)
+	return a
+}
+
+func (a *ScriptArgs) Int(v int64) *ScriptArgs {
+	a.argv = append(a.argv, v)
+	return a
+}
+
+func (a *ScriptArgs) Uint(v uint64) *ScriptArgs {
+	a.argv = append(a.argv, v)
+	return a
+}
+
+// Float 以最短的十进制形式传递，lua中用tonumber读取
+func (a *ScriptArgs) Float(v float64) *ScriptArgs {
+	a.argv = append(a.argv, strconv.FormatFloat(v, 'f', -1, 64))
+	return a
+}
+
+// Millis 以毫秒传递时长
+func (a *ScriptArgs) Millis(v time.Duration) *ScriptArgs {
+	a.argv = append(a.argv, v.Milliseconds())
+	return a
+}
+
+// Seconds 以秒传递时长，不足一秒的部分被舍去
+func (a *ScriptArgs) Seconds(v time.Duration) *ScriptArgs {
+	a.argv = append(a.argv, int64(v/time.Second))
+	return a
+}
+
+func (s *Script) keysAndArgs(keys []string, argv []interface{}) ([]interface{}, error) {
+	if len(keys) != s.keyCount {
+		return nil, errors.Errorf("script %s takes %d keys, got %d", s.name, s.keyCount, len(keys))
+	}
+
+	keysAndArgs := make([]interface{}, 0, len(keys)+len(argv))
+	for _, key := range keys {
+		keysAndArgs = append(keysAndArgs, key)
+	}
+	return append(keysAndArgs, argv...), nil
+}
+
+// RunConn 在conn上执行脚本
+func (s *Script) RunConn(ctx context.Context, conn redis.Conn, args *ScriptArgs) (interface{}, error) {
+	keysAndArgs, err := s.keysAndArgs(args.keys, args.argv)
+	if err != nil {
+		return nil, err
+	}
+	return s.scr.DoContext(ctx, conn, keysAndArgs...)
+}
+
+// Run 在d上执行脚本，WithPrefix生成的DB会给KEYS加上前缀，WithHooks的hooks也会被调用
+func (s *Script) Run(ctx context.Context, d DB, args *ScriptArgs) (interface{}, error) {
+	return runScript(ctx, d, s, args.keys, args.argv)
+}
+
+// scriptRunner is implemented by DBs which run scripts themselves, to prefix keys or call hooks
+type scriptRunner interface {
+	runScript(ctx context.Context, s *Script, keys []string, argv []interface{}) (interface{}, error)
+}
+
+func runScript(ctx context.Context, d interface{ Pool() *redis.Pool }, s *Script, keys []string, argv []interface{}) (interface{}, error) {
+	if r, ok := d.(scriptRunner); ok {
+		return r.runScript(ctx, s, keys, argv)
+	}
+
+	pool := d.Pool()
+	if pool == nil {
+		return nil, ErrScriptNotSupported
+	}
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return s.RunConn(ctx, conn, &ScriptArgs{keys, argv})
+}
+
+func (d *db) runScript(ctx context.Context, s *Script, keys []string, argv []interface{}) (interface{}, error) {
+	keysAndArgs, err := s.keysAndArgs(keys, argv)
+	if err != nil {
+		return nil, err
+	}
+	return d.doScript(ctx, s, keysAndArgs...)
+}
+
+func (p *prefixDB) runScript(ctx context.Context, s *Script, keys []string, argv []interface{}) (interface{}, error) {
+	return runScript(ctx, p.cdb, s, p.keys(keys), argv)
+}
+
+func (a *contextAdapter) runScript(ctx context.Context, s *Script, keys []string, argv []interface{}) (interface{}, error) {
+	return runScript(ctx, a.d, s, keys, argv)
+}
+
+var scriptRegistry = struct {
+	sync.RWMutex
+	scripts map[string]*Script
+	names   []string
+}{scripts: map[string]*Script{}}
+
+// RegisterScript 注册名为name的脚本，keyCount为KEYS的个数，重复注册同一个名字会panic
+// Scripts are usually registered as package variables, names are better namespaced
+// by the package, such as "ratelimit.token_bucket".
+func RegisterScript(name string, keyCount int, src string) *Script {
+	scriptRegistry.Lock()
+	defer scriptRegistry.Unlock()
+
+	if _, ok := scriptRegistry.scripts[name]; ok {
+		panic(fmt.Sprintf("redis: script %s registered twice", name))
+	}
+
+	s := &Script{name, keyCount, src, redis.NewScript(keyCount, src)}
+	scriptRegistry.scripts[name] = s
+	scriptRegistry.names = append(scriptRegistry.names, name)
+	return s
+}
+
+// LookupScript 按名字查找注册过的脚本，没有时返回nil
+func LookupScript(name string) *Script {
+	scriptRegistry.RLock()
+	defer scriptRegistry.RUnlock()
+	return scriptRegistry.scripts[name]
+}
+
+// LoadScripts 用SCRIPT LOAD把所有注册过的脚本预先加载到服务器
+// Scripts are loaded on demand by EVAL anyway, preloading saves the failed EVALSHA of
+// the first calls. Scripts registered later are not loaded.
+func LoadScripts(ctx context.Context, pool *redis.Pool) error {
+	if pool == nil {
+		return ErrScriptNotSupported
+	}
+
+	scriptRegistry.RLock()
+	scripts := make([]*Script, len(scriptRegistry.names))
+	for i, name := range scriptRegistry.names {
+		scripts[i] = scriptRegistry.scripts[name]
+	}
+	scriptRegistry.RUnlock()
+
+	return loadScripts(ctx, pool, scripts)
+}
+
+// loadScripts pipelines SCRIPT LOAD of scripts, the first script refused by the server
+// such as for a syntax error fails it
+func loadScripts(ctx context.Context, pool *redis.Pool, scripts []*Script) error {
+	conn, err := pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, s := range scripts {
+		if err := conn.Send("SCRIPT", "LOAD", s.src); err != nil {
+			return err
+		}
+	}
+	// errors in the replies of a pipeline are returned as values
+	replies, err := redis.Values(redis.DoContext(conn, ctx, ""))
+	if err != nil {
+		return err
+	}
+	for i, reply := range replies {
+		if e, ok := reply.(redis.Error); ok {
+			return errors.Wrapf(e, "failed to load script %s", scripts[i].name)
+		}
+	}
+	return nil
+}
+
+var (
+	// DelIfEqualScript 值等于ARGV[1]时删除KEYS[1]，返回删除的个数
+	DelIfEqualScript = RegisterScript("redis.del_if_equal", 1, delKeyValueScript)
+	// ExpireIfEqualScript 值等于ARGV[1]时把KEYS[1]的过期时间设为ARGV[2]秒，成功返回1
+	ExpireIfEqualScript = RegisterScript("redis.expire_if_equal", 1, setLockTTLScript)
+	// PExpireIfEqualScript 同ExpireIfEqualScript，ARGV[2]的单位为毫秒
+	PExpireIfEqualScript = RegisterScript("redis.pexpire_if_equal", 1, pexpireIfEqualScript)
+
+	setNotExistsScr = RegisterScript("redis.set_not_exists", 1, setNotExistsScript)
+	replaceValueScr = RegisterScript("redis.replace_value", 1, replaceValueScript)
+	cmpSetScr       = RegisterScript("redis.cmp_and_set", 2, cmpSetScript)
+	cmpGTDecrScr    = RegisterScript("redis.cmp_gt_decr", 1, cmpGTDecrScript)
+	incrToScr       = RegisterScript("redis.incr_to", 1, incrToScript)
+	hsetExpireScr   = RegisterScript("redis.hset_expire", 1, hsetExpireScript)
+)
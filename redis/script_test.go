@@ -0,0 +1,101 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+var testIncrCapScript = RegisterScript("redis_test.incr_cap", 1, `local n = redis.call("INCRBY", KEYS[1], ARGV[1])
+if n > tonumber(ARGV[2]) then
+	redis.call("SET", KEYS[1], ARGV[2])
+	n = tonumber(ARGV[2])
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return n`)
+
+func TestScripts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	db := NewDB(env.RedisHost, "", 3)
+	ctx := context.Background()
+
+	conn := db.Pool().Get()
+	defer conn.Close()
+	_, err := conn.Do("SCRIPT", "FLUSH")
+	assert.NoError(t, err)
+
+	// EVALSHA falls back to EVAL before the script is loaded
+	args := NewScriptArgs("script_counter").Int(3).Int(5).Millis(time.Minute)
+	n, err := redis.Int(testIncrCapScript.Run(ctx, db, args))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	assert.NoError(t, LoadScripts(ctx, db.Pool()))
+	exists, err := redis.Ints(conn.Do("SCRIPT", "EXISTS", testIncrCapScript.Hash(), DelIfEqualScript.Hash(), cmpSetScr.Hash()))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 1, 1}, exists)
+
+	n, err = redis.Int(testIncrCapScript.Run(ctx, db, args))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+
+	// a script refused by the server fails the preloading
+	broken := &Script{"redis_test.broken", 0, "return (", redis.NewScript(0, "return (")}
+	err = loadScripts(ctx, db.Pool(), []*Script{testIncrCapScript, broken})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "redis_test.broken")
+
+	// keys are prefixed and hooks are called
+	rec := &recordHook{}
+	svc := WithHooks(WithPrefix(db, "svc:"), rec)
+	_, err = testIncrCapScript.Run(ctx, svc, NewScriptArgs("script_counter").Int(1).Int(5).Millis(time.Minute))
+	assert.NoError(t, err)
+	val := 0
+	assert.NoError(t, db.Get("svc:script_counter", &val))
+	assert.Equal(t, 1, val)
+	assert.NotNil(t, rec.find("EVALSHA", "svc:script_counter"))
+
+	_, err = testIncrCapScript.Run(ctx, db, NewScriptArgs("k1", "k2"))
+	assert.Error(t, err)
+
+	// the built-in scripts still work with the raw connection
+	assert.NoError(t, db.Set("script_lock", "secret", 0))
+	_, err = DelIfEqualScript.RunConn(ctx, conn, NewScriptArgs("script_lock").Bytes([]byte(`"secret"`)))
+	assert.NoError(t, err)
+	found, _ := db.Exists("script_lock")
+	assert.False(t, found)
+}
+
+func TestMemoryScripts(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemoryDB(nil)
+	_, err := testIncrCapScript.Run(ctx, db, NewScriptArgs("k").Int(1).Int(1).Int(1))
+	assert.Equal(t, ErrScriptNotSupported, err)
+	_, err = testIncrCapScript.Run(ctx, WithPrefix(db, "svc:"), NewScriptArgs("k").Int(1).Int(1).Int(1))
+	assert.Equal(t, ErrScriptNotSupported, err)
+	assert.Equal(t, ErrScriptNotSupported, LoadScripts(ctx, db.Pool()))
+}
+
+func TestRegisterScript(t *testing.T) {
+	assert.Equal(t, testIncrCapScript, LookupScript("redis_test.incr_cap"))
+	assert.Equal(t, "redis.del_if_equal", LookupScript("redis.del_if_equal").Name())
+	assert.Nil(t, LookupScript("not_registered"))
+	assert.Panics(t, func() {
+		RegisterScript("redis_test.incr_cap", 1, "return 1")
+	})
+
+	args := NewScriptArgs("k").String("s").Float(0.5).Seconds(1500 * time.Millisecond).Uint(7)
+	assert.Equal(t, []interface{}{"s", "0.5", int64(1), uint64(7)}, args.argv)
+}
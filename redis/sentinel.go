@@ -0,0 +1,193 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/gor"
+	"github.com/chenjie4255/tools/log"
+	"github.com/gomodule/redigo/redis"
+)
+
+const sentinelCheckInterval = time.Second
+
+var errNoSentinel = errors.New("no sentinel is available")
+
+// sentinel discovers the master address of masterName
+type sentinel struct {
+	masterName string
+
+	mu         sync.Mutex
+	addrs      []string
+	master     string
+	checkedAt  time.Time
+	refreshing bool
+}
+
+// discover asks sentinels in order for the master address, a sentinel that answers is
+// moved to the front so it is asked first next time
+func (s *sentinel) discover(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	addrs := append([]string(nil), s.addrs...)
+	s.mu.Unlock()
+
+	var lastErr error = errNoSentinel
+	for i, addr := range addrs {
+		master, err := s.queryMaster(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		s.mu.Lock()
+		if i > 0 {
+			s.addrs = append([]string{addr}, append(addrs[:i], addrs[i+1:]...)...)
+		}
+		if s.master != "" && s.master != master {
+			logger.AddFile().WithFields(log.Fields{
+				"master_name": s.masterName,
+				"from":        s.master,
+				"to":          master,
+			}).Warn("redis master is switched")
+		}
+		s.master = master
+		s.checkedAt = time.Now()
+		s.mu.Unlock()
+
+		return master, nil
+	}
+
+	s.mu.Lock()
+	s.checkedAt = time.Now()
+	s.mu.Unlock()
+
+	return "", lastErr
+}
+
+func (s *sentinel) queryMaster(ctx context.Context, addr string) (string, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	c, err := redis.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	res, err := redis.Strings(redis.DoContext(c, dialCtx, "SENTINEL", "get-master-addr-by-name", s.masterName))
+	if err != nil {
+		if err == redis.ErrNil {
+			return "", errors.Errorf("sentinel %s doesn't know master %s", addr, s.masterName)
+		}
+		return "", err
+	}
+	if len(res) != 2 {
+		return "", errors.Errorf("unexpected sentinel reply %v", res)
+	}
+
+	return net.JoinHostPort(res[0], res[1]), nil
+}
+
+// cachedMaster returns the master address known so far without waiting for sentinels,
+// they are asked again in the background at most once per sentinelCheckInterval
+func (s *sentinel) cachedMaster() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.checkedAt) >= sentinelCheckInterval && !s.refreshing {
+		s.refreshing = true
+		gor.RunWithRecover(func() {
+			defer func() {
+				s.mu.Lock()
+				s.refreshing = false
+				s.mu.Unlock()
+			}()
+			s.discover(context.Background())
+		})
+	}
+	return s.master, s.master != ""
+}
+
+// addrConn remembers the address a connection is dialed to
+type addrConn struct {
+	redis.Conn
+	addr string
+}
+
+func (c addrConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	return redis.DoContext(c.Conn, ctx, cmd, args...)
+}
+
+func (c addrConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+}
+
+func (c addrConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	return redis.ReceiveContext(c.Conn, ctx)
+}
+
+func (c addrConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+// checkMasterRole makes sure a failed over master which is demoted is not used
+func checkMasterRole(ctx context.Context, c redis.Conn) error {
+	values, err := redis.Values(redis.DoContext(c, ctx, "ROLE"))
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return errors.New("unexpected ROLE reply")
+	}
+
+	role, _ := redis.String(values[0], nil)
+	if !strings.EqualFold(role, "master") {
+		return errors.Errorf("redis role is %s instead of master", role)
+	}
+	return nil
+}
+
+// NewSentinelPool 通过Sentinel发现master的redis.Pool，failover后自动连接新的master
+// Connections to the old master are dropped when they are borrowed after a background
+// check has found the switch, opts apply to the connections to the master.
+func NewSentinelPool(sentinels []string, masterName, password string, db int, opts ...PoolOption) *redis.Pool {
+	s := &sentinel{masterName: masterName, addrs: append([]string(nil), sentinels...)}
+	c := newPoolConfig(password, db, opts)
+
+	pool := c.newPool(func(ctx context.Context) (redis.Conn, error) {
+		master, err := s.discover(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := c.dial(ctx, master)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkMasterRole(ctx, conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return addrConn{conn, master}, nil
+	})
+
+	testOnBorrow := pool.TestOnBorrow
+	pool.TestOnBorrow = func(conn redis.Conn, t time.Time) error {
+		// borrowing never waits for sentinels, the connection is kept if no master is known
+		master, found := s.cachedMaster()
+		if ac, ok := conn.(addrConn); ok && found && ac.addr != master {
+			return errors.Errorf("%s is not the master any more", ac.addr)
+		}
+		return testOnBorrow(conn, t)
+	}
+	return pool
+}
+
+// NewSentinelDB 生成通过Sentinel发现master的DB
+func NewSentinelDB(sentinels []string, masterName, password string, dbNum int, opts ...PoolOption) DB {
+	return NewDBFromPool(NewSentinelPool(sentinels, masterName, password, dbNum, opts...))
+}
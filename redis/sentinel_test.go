@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSentinel answers every command with the address of master
+func fakeSentinel(t *testing.T, master string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, _ := net.SplitHostPort(master)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					// a command is sent as an array of 3 bulk strings
+					for i := 0; i < 7; i++ {
+						if _, err := r.ReadString('\n'); err != nil {
+							return
+						}
+					}
+					conn.Write([]byte("*2\r\n$" + strconv.Itoa(len(host)) + "\r\n" + host + "\r\n$" + strconv.Itoa(len(port)) + "\r\n" + port + "\r\n"))
+				}
+			}()
+		}
+	}()
+	return ln
+}
+
+func TestSentinelDiscover(t *testing.T) {
+	ln := fakeSentinel(t, "10.0.0.1:6379")
+	defer ln.Close()
+
+	s := &sentinel{masterName: "mymaster", addrs: []string{"127.0.0.1:1", ln.Addr().String()}}
+	master, err := s.discover(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:6379", master)
+	// the sentinel answered is asked first next time
+	assert.Equal(t, []string{ln.Addr().String(), "127.0.0.1:1"}, s.addrs)
+
+	master, found := s.cachedMaster()
+	assert.True(t, found)
+	assert.Equal(t, "10.0.0.1:6379", master)
+
+	s = &sentinel{masterName: "mymaster", addrs: []string{"127.0.0.1:1"}}
+	_, err = s.discover(context.Background())
+	assert.Error(t, err)
+	_, found = s.cachedMaster()
+	assert.False(t, found)
+
+	// an outdated master is returned at once and refreshed in the background
+	s = &sentinel{masterName: "mymaster", addrs: []string{ln.Addr().String()}, master: "10.0.0.2:6379"}
+	master, found = s.cachedMaster()
+	assert.True(t, found)
+	assert.Equal(t, "10.0.0.2:6379", master)
+	assert.Eventually(t, func() bool {
+		master, _ := s.cachedMaster()
+		return master == "10.0.0.1:6379"
+	}, time.Second, 10*time.Millisecond)
+}
@@ -1,12 +1,15 @@
 package redis
 
 import (
+	"context"
 	"fmt"
-	"github.com/chenjie4255/tools/errcode"
-	"github.com/chenjie4255/tools/gor"
-	"github.com/chenjie4255/errors"
 	"sync"
 	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/gor"
+	"github.com/chenjie4255/tools/log"
 )
 
 const (
@@ -16,67 +19,95 @@ const (
 
 type shareInfo struct {
 	redisDB DB
-
-	rwlock sync.RWMutex
+	sub     Subscriber
 
 	// keys
 	channelKey string
 	valueKey   string
 
-	data             string
-	refreshInterval  int
-	refreshCountDown int
+	rwlock sync.RWMutex
+	data   string
+
+	refreshInterval time.Duration
+	stop            chan struct{}
+	closeOnce       sync.Once
 }
 
-func NewShareInfo(infoName string, redisDB DB, forceRefresh int) ShareInfo {
+// NewShareInfo 生成ShareInfo，每forceRefresh秒从redis强制刷新一次，不大于0时每秒刷新
+// The ShareInfo is closed when ctx is done.
+func NewShareInfo(ctx context.Context, infoName string, redisDB DB, forceRefresh int) ShareInfo {
 	ret := shareInfo{}
 	ret.redisDB = redisDB
 
 	ret.valueKey = fmt.Sprintf("redis_share_info_%s", infoName)
 	ret.channelKey = fmt.Sprintf("redis_share_info_channel_%s", infoName)
-	ret.refreshInterval = forceRefresh
-	ret.refreshCountDown = forceRefresh
+	ret.refreshInterval = time.Duration(forceRefresh) * time.Second
+	if ret.refreshInterval <= 0 {
+		ret.refreshInterval = time.Second
+	}
+	ret.stop = make(chan struct{})
 
 	ret.data, _ = ret.getFromRedis()
 
-	done := make(chan bool)
-	go ret.asyncWatch(done)
-	<-done
+	ret.watch()
 
-	ret.loopRefresh()
+	ret.loopRefresh(ctx)
 
 	return &ret
 }
 
-func (i *shareInfo) loopRefresh() {
+func (i *shareInfo) loopRefresh(ctx context.Context) {
 	gor.RunWithRecover(func() {
+		ticker := time.NewTicker(i.refreshInterval)
+		defer ticker.Stop()
+
 		for {
-			time.Sleep(1 * time.Second)
-			i.refreshCountDown--
-			if i.refreshCountDown <= 0 {
-				i.data, _ = i.getFromRedis()
-				i.refreshCountDown = i.refreshInterval
+			select {
+			case <-ctx.Done():
+				i.Close()
+				return
+			case <-i.stop:
+				return
+			case <-ticker.C:
+				i.reload()
 			}
 		}
 	})
 }
 
-func (i *shareInfo) asyncWatch(done chan bool) {
-	i.redisDB.Subscribe([]string{i.channelKey}, done, func(channel string, data []byte) {
-		if string(data) == ShareInfoUpdatedEvent {
-			val, err := i.getFromRedis()
-			if err == nil {
-				i.rwlock.Lock()
-				i.data = val
-				i.rwlock.Unlock()
-				i.refreshCountDown = i.refreshInterval
-			}
-		} else if string(data) == ShareInfoDeletedEvent {
-			i.rwlock.Lock()
-			i.data = ""
-			i.rwlock.Unlock()
-		}
+// watch keeps the local copy updated by messages, the value is reloaded after
+// reconnecting since messages may be lost while disconnected
+func (i *shareInfo) watch() {
+	i.sub = i.redisDB.NewSubscriber(i.onMessage, SubscriberOptions{
+		OnReconnect: i.reload,
 	})
+	if err := i.sub.Subscribe(i.channelKey); err != nil {
+		logger.AddFile().WithFields(log.Fields{
+			"channel": i.channelKey,
+			"error":   err,
+		}).Warn("failed to subscribe share info")
+	}
+}
+
+func (i *shareInfo) setData(val string) {
+	i.rwlock.Lock()
+	i.data = val
+	i.rwlock.Unlock()
+}
+
+func (i *shareInfo) reload() {
+	val, err := i.getFromRedis()
+	if err == nil {
+		i.setData(val)
+	}
+}
+
+func (i *shareInfo) onMessage(channel string, data []byte) {
+	if string(data) == ShareInfoUpdatedEvent {
+		i.reload()
+	} else if string(data) == ShareInfoDeletedEvent {
+		i.setData("")
+	}
 }
 
 func (i *shareInfo) Set(val string) error {
@@ -84,7 +115,7 @@ func (i *shareInfo) Set(val string) error {
 		return err
 	}
 
-	i.data = val
+	i.setData(val)
 
 	_, err := i.redisDB.Publish(i.channelKey, []byte(ShareInfoUpdatedEvent))
 	return err
@@ -100,8 +131,12 @@ func (i *shareInfo) getFromRedis() (string, error) {
 }
 
 func (i *shareInfo) Get() (string, error) {
-	if i.data != "" {
-		return i.data, nil
+	i.rwlock.RLock()
+	data := i.data
+	i.rwlock.RUnlock()
+
+	if data != "" {
+		return data, nil
 	}
 
 	return i.getFromRedis()
@@ -112,8 +147,16 @@ func (i *shareInfo) Del(oldVal string) error {
 		return err
 	}
 
-	i.data = ""
+	i.setData("")
 
 	_, err := i.redisDB.Publish(i.channelKey, []byte(ShareInfoDeletedEvent))
 	return err
 }
+
+func (i *shareInfo) Close() error {
+	i.closeOnce.Do(func() {
+		close(i.stop)
+		i.sub.Close()
+	})
+	return nil
+}
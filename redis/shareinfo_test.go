@@ -1,10 +1,14 @@
 package redis
 
 import (
-	"github.com/chenjie4255/tools/testenv"
-	"github.com/stretchr/testify/assert"
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestShareInfo(t *testing.T) {
@@ -18,14 +22,16 @@ func TestShareInfo(t *testing.T) {
 
 	FlushDB(env.RedisHost, "", 3)
 	db := NewDB(env.RedisHost, "", 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	shareInfo := NewShareInfo("test_k", db, 100)
-	shareInfo1 := NewShareInfo("test_k", db, 100)
-	shareInfo2 := NewShareInfo("test_k", db, 100)
-	shareInfo3 := NewShareInfo("test_k", db, 100)
-	shareInfo4 := NewShareInfo("test_k", db, 100)
-	shareInfo5 := NewShareInfo("test_k", db, 100)
-	shareInfo6 := NewShareInfo("test_k", db, 100)
+	shareInfo := NewShareInfo(ctx, "test_k", db, 100)
+	shareInfo1 := NewShareInfo(ctx, "test_k", db, 100)
+	shareInfo2 := NewShareInfo(ctx, "test_k", db, 100)
+	shareInfo3 := NewShareInfo(ctx, "test_k", db, 100)
+	shareInfo4 := NewShareInfo(ctx, "test_k", db, 100)
+	shareInfo5 := NewShareInfo(ctx, "test_k", db, 100)
+	shareInfo6 := NewShareInfo(ctx, "test_k", db, 100)
 
 	err := shareInfo.Set("hi")
 	assert.NoError(t, err)
@@ -62,6 +68,8 @@ func TestShareInfo(t *testing.T) {
 	err = shareInfo1.Del("hi")
 	assert.NoError(t, err)
 
+	time.Sleep(1 * time.Second)
+
 	val, err = shareInfo1.Get()
 	assert.NoError(t, err)
 	assert.Equal(t, "", string(val))
@@ -86,3 +94,65 @@ func TestShareInfo(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "", string(val))
 }
+
+func TestShareInfoConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	runShareInfoConcurrentSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func TestMemoryShareInfoConcurrent(t *testing.T) {
+	runShareInfoConcurrentSuite(t, NewMemoryDB(nil))
+}
+
+// runShareInfoConcurrentSuite is meant to be run with -race
+func runShareInfoConcurrentSuite(t *testing.T, db DB) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	infos := []ShareInfo{}
+	for i := 0; i < 4; i++ {
+		infos = append(infos, NewShareInfo(ctx, "test_concurrent", db, 1))
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range infos {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+
+			info := infos[idx]
+			for j := 0; j < 50; j++ {
+				val := fmt.Sprintf("%d_%d", idx, j)
+				assert.NoError(t, info.Set(val))
+				_, err := info.Get()
+				assert.NoError(t, err)
+				if j%5 == 0 {
+					// the value may have been replaced by others
+					info.Del(val)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.NoError(t, infos[0].Set("final"))
+	for _, info := range infos {
+		assert.Eventually(t, func() bool {
+			val, err := info.Get()
+			return err == nil && val == "final"
+		}, 3*time.Second, 10*time.Millisecond)
+	}
+
+	// cancelling ctx closes all of them
+	cancel()
+	for _, info := range infos {
+		assert.NoError(t, info.Close())
+	}
+}
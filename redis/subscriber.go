@@ -0,0 +1,498 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/gor"
+	"github.com/chenjie4255/tools/log"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	defaultSubscriberPingInterval     = 30 * time.Second
+	defaultSubscriberMinBackoff       = 100 * time.Millisecond
+	defaultSubscriberMaxBackoff       = 10 * time.Second
+	defaultSubscriberSubscribeTimeout = 5 * time.Second
+)
+
+var (
+	errSubscriberClosed  = errors.New("subscriber is closed")
+	errSubscriberTimeout = errors.New("subscription is not confirmed in time, it will be retried after reconnecting")
+)
+
+// Subscriber 自动重连的订阅者，断线后按退避间隔重连并重新订阅所有频道和模式
+// Channels and patterns can be changed at any time until Close is called.
+type Subscriber interface {
+	// Subscribe 订阅频道，等待服务端确认后返回
+	// A channel is kept even if an error is returned, and it is subscribed again
+	// after reconnecting.
+	Subscribe(channels ...string) error
+	Unsubscribe(channels ...string) error
+
+	// PSubscribe 按模式订阅，模式规则与redis的PSUBSCRIBE一致
+	PSubscribe(patterns ...string) error
+	PUnsubscribe(patterns ...string) error
+
+	Close() error
+}
+
+// SubscriberOptions 配置Subscriber的重连与健康检查
+type SubscriberOptions struct {
+	// PingInterval 健康检查间隔，默认30秒，超过两个间隔收不到任何回复时重连
+	// recv blocking longer than that is treated as a broken connection too.
+	PingInterval time.Duration
+
+	// MinBackoff 重连的初始等待时间，默认100毫秒，每次失败后翻倍
+	MinBackoff time.Duration
+
+	// MaxBackoff 重连的最长等待时间，默认10秒
+	MaxBackoff time.Duration
+
+	// SubscribeTimeout Subscribe/PSubscribe等待服务端确认的最长时间，默认5秒
+	SubscribeTimeout time.Duration
+
+	// OnReconnect 重连并重新订阅后调用，可用于补偿断线期间丢失的消息
+	OnReconnect func()
+}
+
+func (opts *SubscriberOptions) setDefaults() {
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = defaultSubscriberPingInterval
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultSubscriberMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultSubscriberMaxBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = opts.MinBackoff
+	}
+	if opts.SubscribeTimeout <= 0 {
+		opts.SubscribeTimeout = defaultSubscriberSubscribeTimeout
+	}
+}
+
+const (
+	subscribeKind  = "subscribe"
+	psubscribeKind = "psubscribe"
+)
+
+type subscriber struct {
+	pool *redis.Pool
+	recv func(channel string, data []byte)
+	opts SubscriberOptions
+
+	mu       sync.Mutex
+	conn     *redis.PubSubConn // nil while disconnected
+	channels map[string]bool
+	patterns map[string]bool
+	waiters  map[string][]chan struct{} // keyed by kind and name
+	closed   bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSubscriber 生成自动重连的订阅者，recv在同一个协程中依次调用
+func (d *db) NewSubscriber(recv func(channel string, data []byte), opts SubscriberOptions) Subscriber {
+	opts.setDefaults()
+
+	s := &subscriber{
+		pool:     d.pool,
+		recv:     recv,
+		opts:     opts,
+		channels: make(map[string]bool),
+		patterns: make(map[string]bool),
+		waiters:  make(map[string][]chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	gor.RunWithRecover(func() {
+		defer close(s.done)
+		s.loop()
+	})
+
+	return s
+}
+
+func (s *subscriber) loop() {
+	backoff := s.opts.MinBackoff
+	reconnect := false
+	for s.ctx.Err() == nil {
+		connected, err := s.serve(reconnect)
+		if s.ctx.Err() != nil {
+			return
+		}
+		if connected {
+			reconnect = true
+			backoff = s.opts.MinBackoff
+		}
+
+		logger.AddFile().WithFields(log.Fields{
+			"error":    err,
+			"retry_in": backoff.String(),
+		}).Warn("subscriber disconnected")
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > s.opts.MaxBackoff {
+			backoff = s.opts.MaxBackoff
+		}
+	}
+}
+
+// serve subscribes everything on a new connection and dispatches messages until the
+// connection is broken
+func (s *subscriber) serve(reconnect bool) (bool, error) {
+	conn, err := s.pool.GetContext(s.ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	connCtx, connCancel := context.WithCancel(s.ctx)
+	defer connCancel()
+
+	pcs := &redis.PubSubConn{Conn: conn}
+	s.mu.Lock()
+	if err := s.subscribeAll(pcs); err != nil {
+		s.mu.Unlock()
+		return false, err
+	}
+	s.conn = pcs
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	if reconnect && s.opts.OnReconnect != nil {
+		gor.RunWithRecover(s.opts.OnReconnect)
+	}
+
+	lastRecv := time.Now().UnixNano()
+	subCount := int64(0)
+	gor.RunWithRecover(func() {
+		s.ping(connCtx, connCancel, pcs, &lastRecv, &subCount)
+	})
+
+	for {
+		reply := pcs.ReceiveContext(connCtx)
+		atomic.StoreInt64(&lastRecv, time.Now().UnixNano())
+
+		switch v := reply.(type) {
+		case redis.Message:
+			if s.active(v) {
+				s.recv(v.Channel, v.Data)
+			}
+		case redis.Subscription:
+			atomic.StoreInt64(&subCount, int64(v.Count))
+			if v.Kind == subscribeKind || v.Kind == psubscribeKind {
+				s.confirm(v.Kind, v.Channel)
+			}
+		case error:
+			return true, v
+		}
+	}
+}
+
+// ping checks the connection while anything is subscribed, PING is only allowed in
+// the subscribe mode
+func (s *subscriber) ping(ctx context.Context, cancel context.CancelFunc, pcs *redis.PubSubConn, lastRecv, subCount *int64) {
+	ticker := time.NewTicker(s.opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if atomic.LoadInt64(subCount) == 0 {
+			atomic.StoreInt64(lastRecv, time.Now().UnixNano())
+			continue
+		}
+
+		if time.Since(time.Unix(0, atomic.LoadInt64(lastRecv))) > 2*s.opts.PingInterval {
+			logger.AddFile().Warn("subscriber health check timeout")
+			cancel()
+			return
+		}
+
+		s.mu.Lock()
+		err := pcs.Ping("")
+		s.mu.Unlock()
+		if err != nil {
+			cancel()
+			return
+		}
+	}
+}
+
+func (s *subscriber) subscribeAll(pcs *redis.PubSubConn) error {
+	if len(s.channels) > 0 {
+		if err := pcs.Subscribe(mapKeys(s.channels)...); err != nil {
+			return err
+		}
+	}
+	if len(s.patterns) > 0 {
+		if err := pcs.PSubscribe(mapKeys(s.patterns)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mapKeys(m map[string]bool) []interface{} {
+	ret := make([]interface{}, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	return ret
+}
+
+func (s *subscriber) active(msg redis.Message) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if msg.Pattern != "" {
+		return s.patterns[msg.Pattern]
+	}
+	return s.channels[msg.Channel]
+}
+
+func (s *subscriber) confirm(kind, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := kind + ":" + name
+	for _, ch := range s.waiters[key] {
+		close(ch)
+	}
+	delete(s.waiters, key)
+}
+
+func (s *subscriber) Subscribe(channels ...string) error {
+	return s.subscribe(subscribeKind, channels)
+}
+
+func (s *subscriber) PSubscribe(patterns ...string) error {
+	return s.subscribe(psubscribeKind, patterns)
+}
+
+func (s *subscriber) subscribe(kind string, names []string) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errSubscriberClosed
+	}
+
+	set := s.channels
+	if kind == psubscribeKind {
+		set = s.patterns
+	}
+
+	args := []interface{}{}
+	waits := []chan struct{}{}
+	for _, name := range names {
+		if set[name] {
+			continue
+		}
+		set[name] = true
+
+		ch := make(chan struct{})
+		key := kind + ":" + name
+		s.waiters[key] = append(s.waiters[key], ch)
+		waits = append(waits, ch)
+		args = append(args, name)
+	}
+
+	var err error
+	if s.conn != nil && len(args) > 0 {
+		if kind == psubscribeKind {
+			err = s.conn.PSubscribe(args...)
+		} else {
+			err = s.conn.Subscribe(args...)
+		}
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(s.opts.SubscribeTimeout)
+	defer timer.Stop()
+	for _, ch := range waits {
+		select {
+		case <-ch:
+		case <-timer.C:
+			return errSubscriberTimeout
+		case <-s.ctx.Done():
+			return errSubscriberClosed
+		}
+	}
+	return nil
+}
+
+func (s *subscriber) Unsubscribe(channels ...string) error {
+	return s.unsubscribe(subscribeKind, channels)
+}
+
+func (s *subscriber) PUnsubscribe(patterns ...string) error {
+	return s.unsubscribe(psubscribeKind, patterns)
+}
+
+func (s *subscriber) unsubscribe(kind string, names []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errSubscriberClosed
+	}
+
+	set := s.channels
+	if kind == psubscribeKind {
+		set = s.patterns
+	}
+
+	args := []interface{}{}
+	for _, name := range names {
+		if set[name] {
+			delete(set, name)
+			args = append(args, name)
+		}
+	}
+
+	if s.conn == nil || len(args) == 0 {
+		return nil
+	}
+	if kind == psubscribeKind {
+		return s.conn.PUnsubscribe(args...)
+	}
+	return s.conn.Unsubscribe(args...)
+}
+
+func (s *subscriber) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// memPubSub is the Subscriber of memoryDB, it never disconnects
+type memPubSub struct {
+	m    *memoryDB
+	sub  *memSubscriber
+	done chan struct{}
+	once sync.Once
+}
+
+func (m *memoryDB) NewSubscriber(recv func(channel string, data []byte), opts SubscriberOptions) Subscriber {
+	s := &memPubSub{
+		m: m,
+		sub: &memSubscriber{
+			channels: make(map[string]bool),
+			patterns: make(map[string]bool),
+			notify:   make(chan struct{}, 1),
+		},
+		done: make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.subs = append(m.subs, s.sub)
+	m.mu.Unlock()
+
+	gor.RunWithRecover(func() {
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-s.sub.notify:
+				for _, msg := range s.sub.pop() {
+					recv(msg.channel, msg.data)
+				}
+			}
+		}
+	})
+
+	return s
+}
+
+func (s *memPubSub) update(set func(sub *memSubscriber) map[string]bool, names []string, value bool) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return errSubscriberClosed
+	default:
+	}
+
+	for _, name := range names {
+		if value {
+			set(s.sub)[name] = true
+		} else {
+			delete(set(s.sub), name)
+		}
+	}
+	return nil
+}
+
+func memChannels(sub *memSubscriber) map[string]bool {
+	return sub.channels
+}
+
+func memPatterns(sub *memSubscriber) map[string]bool {
+	return sub.patterns
+}
+
+func (s *memPubSub) Subscribe(channels ...string) error {
+	return s.update(memChannels, channels, true)
+}
+
+func (s *memPubSub) Unsubscribe(channels ...string) error {
+	return s.update(memChannels, channels, false)
+}
+
+func (s *memPubSub) PSubscribe(patterns ...string) error {
+	return s.update(memPatterns, patterns, true)
+}
+
+func (s *memPubSub) PUnsubscribe(patterns ...string) error {
+	return s.update(memPatterns, patterns, false)
+}
+
+func (s *memPubSub) Close() error {
+	s.once.Do(func() {
+		s.m.mu.Lock()
+		defer s.m.mu.Unlock()
+
+		for i := range s.m.subs {
+			if s.m.subs[i] == s.sub {
+				s.m.subs = append(s.m.subs[:i], s.m.subs[i+1:]...)
+				break
+			}
+		}
+		close(s.done)
+	})
+	return nil
+}
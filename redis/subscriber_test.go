@@ -0,0 +1,202 @@
+package redis
+
+import (
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriber(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	runSubscriberSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func TestMemorySubscriber(t *testing.T) {
+	runSubscriberSuite(t, NewMemoryDB(nil))
+}
+
+type recvMessage struct {
+	channel string
+	data    string
+}
+
+func receiveOne(t *testing.T, ch chan recvMessage) recvMessage {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+	return recvMessage{}
+}
+
+func runSubscriberSuite(t *testing.T, db DB) {
+	ch := make(chan recvMessage, 10)
+	sub := db.NewSubscriber(func(channel string, data []byte) {
+		ch <- recvMessage{channel, string(data)}
+	}, SubscriberOptions{})
+
+	assert.NoError(t, sub.Subscribe("sub_c1", "sub_c2"))
+	assert.NoError(t, sub.PSubscribe("sub_p.*"))
+
+	db.Publish("sub_c1", []byte("1"))
+	db.Publish("sub_cx", []byte("x"))
+	db.Publish("sub_p.a", []byte("2"))
+	assert.Equal(t, recvMessage{"sub_c1", "1"}, receiveOne(t, ch))
+	assert.Equal(t, recvMessage{"sub_p.a", "2"}, receiveOne(t, ch))
+
+	assert.NoError(t, sub.Unsubscribe("sub_c1"))
+	assert.NoError(t, sub.PUnsubscribe("sub_p.*"))
+	assert.NoError(t, sub.Subscribe("sub_c3"))
+	db.Publish("sub_c1", []byte("3"))
+	db.Publish("sub_p.a", []byte("4"))
+	db.Publish("sub_c3", []byte("5"))
+	assert.Equal(t, recvMessage{"sub_c3", "5"}, receiveOne(t, ch))
+
+	assert.NoError(t, sub.Close())
+	assert.NoError(t, sub.Close())
+	assert.Error(t, sub.Subscribe("sub_c4"))
+}
+
+// dropProxy forwards connections to target until drop is called
+type dropProxy struct {
+	ln     net.Listener
+	target string
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newDropProxy(t *testing.T, target string) *dropProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &dropProxy{ln: ln, target: target}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", target)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+
+			p.mu.Lock()
+			p.conns = append(p.conns, conn, upstream)
+			p.mu.Unlock()
+
+			go io.Copy(conn, upstream)
+			go io.Copy(upstream, conn)
+		}
+	}()
+	return p
+}
+
+func (p *dropProxy) drop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *dropProxy) close() {
+	p.ln.Close()
+	p.drop()
+}
+
+func TestSubscriberReconnect(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	proxy := newDropProxy(t, env.RedisHost)
+	defer proxy.close()
+
+	db := NewDB(env.RedisHost, "", 3)
+	proxyDB := NewDB(proxy.ln.Addr().String(), "", 3)
+
+	ch := make(chan recvMessage, 10)
+	reconnected := make(chan struct{}, 1)
+	sub := proxyDB.NewSubscriber(func(channel string, data []byte) {
+		ch <- recvMessage{channel, string(data)}
+	}, SubscriberOptions{
+		MinBackoff: 10 * time.Millisecond,
+		OnReconnect: func() {
+			reconnected <- struct{}{}
+		},
+	})
+	defer sub.Close()
+
+	assert.NoError(t, sub.Subscribe("sub_r1"))
+	assert.NoError(t, sub.PSubscribe("sub_rp.*"))
+
+	proxy.drop()
+	select {
+	case <-reconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("not reconnected")
+	}
+
+	// wait for the subscriptions to be confirmed again
+	assert.Eventually(t, func() bool {
+		count, _ := db.Publish("sub_r1", []byte("1"))
+		return count > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, recvMessage{"sub_r1", "1"}, receiveOne(t, ch))
+
+	db.Publish("sub_rp.a", []byte("2"))
+	for {
+		msg := receiveOne(t, ch)
+		if msg.channel != "sub_r1" {
+			assert.Equal(t, recvMessage{"sub_rp.a", "2"}, msg)
+			break
+		}
+	}
+}
+
+func TestSubscriberHealthCheck(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	db := NewDB(env.RedisHost, "", 3)
+	ch := make(chan recvMessage, 10)
+	sub := db.NewSubscriber(func(channel string, data []byte) {
+		ch <- recvMessage{channel, string(data)}
+	}, SubscriberOptions{PingInterval: 20 * time.Millisecond})
+	defer sub.Close()
+
+	assert.NoError(t, sub.Subscribe("sub_h1"))
+	time.Sleep(200 * time.Millisecond)
+
+	db.Publish("sub_h1", []byte("1"))
+	assert.Equal(t, recvMessage{"sub_h1", "1"}, receiveOne(t, ch))
+}
@@ -0,0 +1,383 @@
+package redis
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/clock"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/log"
+)
+
+const (
+	defaultTieredLocalSize = 1024
+	defaultTieredLocalTTL  = 60
+
+	// lruVersionStripes is the number of write counters fills of the LRU are checked against
+	lruVersionStripes = 64
+)
+
+// TieredCache 本地LRU + redis的两级缓存，Set/Del通过Publish通知其他实例清除本地副本
+type TieredCache interface {
+	Get(key string, v interface{}) error
+	Set(key string, value interface{}, expires int) error
+	Del(key string) error
+	CacheGet(key string, v interface{}, fn FetchFunc, expires int) error
+
+	Stats() TieredCacheStats
+
+	// Close 停止订阅失效消息
+	Close() error
+}
+
+// TieredCacheStats 每一级缓存的命中统计
+type TieredCacheStats struct {
+	LocalHits   uint64
+	LocalMisses uint64
+	RedisHits   uint64
+	RedisMisses uint64
+}
+
+// TieredCacheOptions 配置TieredCache的本地缓存
+type TieredCacheOptions struct {
+	// LocalSize 本地缓存的最大条目数，默认1024
+	LocalSize int
+
+	// LocalTTL 本地副本的最长存活秒数，默认60秒
+	// It also bounds how long a local copy may be stale if an invalidation is lost.
+	LocalTTL int
+
+	// Codec encodes local copies, JSONCodec by default
+	Codec Codec
+
+	// Clock decides local expiry, current time by default
+	Clock clock.Clock
+}
+
+type tieredInvalidation struct {
+	From string `json:"from"`
+	Key  string `json:"key"`
+}
+
+type tieredCache struct {
+	db         DB
+	opts       TieredCacheOptions
+	local      *lruCache
+	instanceID string
+	channelKey string
+	sub        Subscriber
+
+	localHits   uint64
+	localMisses uint64
+	redisHits   uint64
+	redisMisses uint64
+}
+
+// NewTieredCache 生成两级缓存，同名的TieredCache之间互相广播失效消息
+func NewTieredCache(name string, db DB, opts TieredCacheOptions) TieredCache {
+	if opts.LocalSize <= 0 {
+		opts.LocalSize = defaultTieredLocalSize
+	}
+	if opts.LocalTTL <= 0 {
+		opts.LocalTTL = defaultTieredLocalTTL
+	}
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.NewOffsetClock(0)
+	}
+
+	ret := tieredCache{}
+	ret.db = db
+	ret.opts = opts
+	ret.local = newLRUCache(opts.LocalSize)
+	ret.instanceID = randomValue()
+	ret.channelKey = fmt.Sprintf("redis_tiered_cache_channel_%s", name)
+
+	ret.watch()
+
+	return &ret
+}
+
+// watch drops local copies invalidated by other instances, all local copies are
+// dropped after reconnecting since invalidations may be lost while disconnected
+func (c *tieredCache) watch() {
+	c.sub = c.db.NewSubscriber(func(channel string, data []byte) {
+		msg := tieredInvalidation{}
+		if err := json.Unmarshal(data, &msg); err != nil || msg.From == c.instanceID {
+			return
+		}
+		c.local.remove(msg.Key)
+	}, SubscriberOptions{
+		OnReconnect: c.local.clear,
+	})
+
+	if err := c.sub.Subscribe(c.channelKey); err != nil {
+		logger.AddFile().WithFields(log.Fields{
+			"channel": c.channelKey,
+			"error":   err,
+		}).Warn("failed to subscribe tiered cache invalidations")
+	}
+}
+
+func (c *tieredCache) publish(key string) error {
+	data, _ := json.Marshal(tieredInvalidation{c.instanceID, key})
+	_, err := c.db.Publish(c.channelKey, data)
+	return err
+}
+
+// localExpireAt bounds a local copy by both LocalTTL and the redis expiry
+func (c *tieredCache) localExpireAt(expires int) int64 {
+	ttl := c.opts.LocalTTL
+	if expires > 0 && expires < ttl {
+		ttl = expires
+	}
+	return c.opts.Clock.GetUnix() + int64(ttl)
+}
+
+func (c *tieredCache) getLocal(key string, v interface{}) bool {
+	data, found := c.local.get(key, c.opts.Clock.GetUnix())
+	if !found || c.opts.Codec.Unmarshal(data, v) != nil {
+		atomic.AddUint64(&c.localMisses, 1)
+		return false
+	}
+
+	atomic.AddUint64(&c.localHits, 1)
+	return true
+}
+
+func (c *tieredCache) setLocal(key string, value interface{}, expires int) {
+	data, err := c.opts.Codec.Marshal(value)
+	if err != nil {
+		return
+	}
+	c.local.add(key, data, c.localExpireAt(expires))
+}
+
+// fillLocal keeps a value read from redis as the local copy, unless key has been
+// written or invalidated locally since version was taken
+func (c *tieredCache) fillLocal(key string, value interface{}, expires int, version uint64) {
+	data, err := c.opts.Codec.Marshal(value)
+	if err != nil {
+		return
+	}
+	c.local.fill(key, data, c.localExpireAt(expires), version)
+}
+
+func (c *tieredCache) Get(key string, v interface{}) error {
+	if c.getLocal(key, v) {
+		return nil
+	}
+
+	version := c.local.version(key)
+	pl := c.db.Pipeline()
+	get := pl.Get(key, v)
+	ttl := pl.TTL(key)
+	if err := pl.Exec(); err != nil {
+		return err
+	}
+	if err := get.Err(); err != nil {
+		if errors.FindTag(err, errcode.ResNotFound) {
+			atomic.AddUint64(&c.redisMisses, 1)
+		}
+		return err
+	}
+
+	atomic.AddUint64(&c.redisHits, 1)
+	// the local copy must not outlive the redis key, -1 means the key never expires
+	expires, err := ttl.Int()
+	if err != nil || expires == 0 {
+		return nil
+	}
+	if expires < 0 {
+		expires = 0
+	}
+	c.fillLocal(key, v, expires, version)
+	return nil
+}
+
+func (c *tieredCache) Set(key string, value interface{}, expires int) error {
+	if err := c.db.Set(key, value, expires); err != nil {
+		return err
+	}
+
+	c.setLocal(key, value, expires)
+	return c.publish(key)
+}
+
+func (c *tieredCache) Del(key string) error {
+	if err := c.db.Del(key); err != nil {
+		return err
+	}
+
+	c.local.remove(key)
+	return c.publish(key)
+}
+
+func (c *tieredCache) CacheGet(key string, v interface{}, fn FetchFunc, expires int) error {
+	if c.getLocal(key, v) {
+		return nil
+	}
+
+	version := c.local.version(key)
+	hit, err := c.db.CacheGetB(key, v, fn, expires)
+	if err != nil {
+		return err
+	}
+
+	if hit {
+		atomic.AddUint64(&c.redisHits, 1)
+		// a value found in redis may have less time left than expires
+		ttl, err := c.db.TTL(key)
+		if err != nil || ttl == 0 {
+			return nil
+		}
+		if ttl > 0 && (expires <= 0 || ttl < expires) {
+			expires = ttl
+		}
+	} else {
+		atomic.AddUint64(&c.redisMisses, 1)
+	}
+	c.fillLocal(key, v, expires, version)
+	return nil
+}
+
+func (c *tieredCache) Stats() TieredCacheStats {
+	return TieredCacheStats{
+		LocalHits:   atomic.LoadUint64(&c.localHits),
+		LocalMisses: atomic.LoadUint64(&c.localMisses),
+		RedisHits:   atomic.LoadUint64(&c.redisHits),
+		RedisMisses: atomic.LoadUint64(&c.redisMisses),
+	}
+}
+
+func (c *tieredCache) Close() error {
+	return c.sub.Close()
+}
+
+type lruEntry struct {
+	key      string
+	data     []byte
+	expireAt int64
+}
+
+// lruCache 有容量上限的LRU，条目带过期时间
+// Every add, remove and clear bumps the version of the keys, so that fill can skip
+// values read before a write. Keys share lruVersionStripes versions.
+type lruCache struct {
+	mu       sync.Mutex
+	size     int
+	ll       *list.List
+	items    map[string]*list.Element
+	versions [lruVersionStripes]uint64
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (l *lruCache) get(key string, now int64) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem, found := l.items[key]
+	if !found {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if now >= entry.expireAt {
+		l.ll.Remove(elem)
+		delete(l.items, key)
+		return nil, false
+	}
+
+	l.ll.MoveToFront(elem)
+	return entry.data, true
+}
+
+func (l *lruCache) stripe(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % lruVersionStripes)
+}
+
+func (l *lruCache) version(key string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.versions[l.stripe(key)]
+}
+
+// fill adds key only if its version is still version
+func (l *lruCache) fill(key string, data []byte, expireAt int64, version uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.versions[l.stripe(key)] != version {
+		return
+	}
+	l.put(key, data, expireAt)
+}
+
+func (l *lruCache) add(key string, data []byte, expireAt int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.versions[l.stripe(key)]++
+	l.put(key, data, expireAt)
+}
+
+func (l *lruCache) put(key string, data []byte, expireAt int64) {
+	if elem, found := l.items[key]; found {
+		elem.Value = &lruEntry{key, data, expireAt}
+		l.ll.MoveToFront(elem)
+		return
+	}
+
+	l.items[key] = l.ll.PushFront(&lruEntry{key, data, expireAt})
+	for l.ll.Len() > l.size {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lruCache) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.versions[l.stripe(key)]++
+	if elem, found := l.items[key]; found {
+		l.ll.Remove(elem)
+		delete(l.items, key)
+	}
+}
+
+func (l *lruCache) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range l.versions {
+		l.versions[i]++
+	}
+	l.ll.Init()
+	l.items = make(map[string]*list.Element)
+}
+
+func (l *lruCache) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.ll.Len()
+}
@@ -0,0 +1,143 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/clock"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredCache(t *testing.T) {
+	db := NewMemoryDB(nil)
+	c1 := NewTieredCache("test", db, TieredCacheOptions{})
+	c2 := NewTieredCache("test", db, TieredCacheOptions{})
+
+	assert.NoError(t, c1.Set("k1", "v1", 100))
+
+	check := ""
+	assert.NoError(t, c2.Get("k1", &check))
+	assert.Equal(t, "v1", check)
+	assert.NoError(t, c2.Get("k1", &check))
+	assert.Equal(t, TieredCacheStats{LocalHits: 1, LocalMisses: 1, RedisHits: 1}, c2.Stats())
+
+	// set on c1 evicts the local copy of c2
+	assert.NoError(t, c1.Set("k1", "v2", 100))
+	assert.Eventually(t, func() bool {
+		return c2.(*tieredCache).local.len() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, c2.Get("k1", &check))
+	assert.Equal(t, "v2", check)
+
+	// so does del
+	assert.NoError(t, c1.Del("k1"))
+	assert.Eventually(t, func() bool {
+		return c2.(*tieredCache).local.len() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	err := c2.Get("k1", &check)
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+	assert.Equal(t, uint64(1), c2.Stats().RedisMisses)
+
+	// close stops the subscriptions
+	assert.NoError(t, c1.Close())
+	assert.NoError(t, c2.Close())
+	m := db.(*memoryDB)
+	m.mu.Lock()
+	assert.Empty(t, m.subs)
+	m.mu.Unlock()
+}
+
+func TestTieredCacheGet(t *testing.T) {
+	clk := clock.NewFixedClock(1000)
+	db := NewMemoryDB(clk)
+	c := NewTieredCache("test", db, TieredCacheOptions{LocalTTL: 10, Clock: clk})
+	defer c.Close()
+
+	count := 0
+	fn := func() (interface{}, error) {
+		count++
+		return count, nil
+	}
+
+	check := 0
+	assert.NoError(t, c.CacheGet("k1", &check, fn, 100))
+	assert.NoError(t, c.CacheGet("k1", &check, fn, 100))
+	assert.Equal(t, 1, check)
+
+	// local copy expires, redis still has the value
+	clk.SetTs(1010)
+	assert.NoError(t, c.CacheGet("k1", &check, fn, 100))
+	assert.Equal(t, 1, check)
+	assert.Equal(t, 1, count)
+
+	assert.Equal(t, TieredCacheStats{LocalHits: 1, LocalMisses: 2, RedisHits: 1, RedisMisses: 1}, c.Stats())
+}
+
+func TestTieredCacheLocalBound(t *testing.T) {
+	clk := clock.NewFixedClock(1000)
+	db := NewMemoryDB(clk)
+	c := NewTieredCache("test", db, TieredCacheOptions{LocalTTL: 10, Clock: clk})
+	defer c.Close()
+
+	// a local copy does not outlive the redis key
+	assert.NoError(t, db.Set("k1", "v1", 5))
+	check := ""
+	assert.NoError(t, c.Get("k1", &check))
+	assert.Equal(t, "v1", check)
+	clk.SetTs(1005)
+	err := c.Get("k1", &check)
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+
+	// so does a value found by CacheGet
+	other := NewTieredCache("other", db, TieredCacheOptions{Clock: clk})
+	defer other.Close()
+	fn := func() (interface{}, error) {
+		return "fetched", nil
+	}
+	assert.NoError(t, other.CacheGet("k3", &check, fn, 3))
+	assert.NoError(t, c.CacheGet("k3", &check, func() (interface{}, error) {
+		return "refetched", nil
+	}, 100))
+	assert.Equal(t, "fetched", check)
+	clk.SetTs(1008)
+	assert.NoError(t, c.CacheGet("k3", &check, func() (interface{}, error) {
+		return "refetched", nil
+	}, 100))
+	assert.Equal(t, "refetched", check)
+
+	// a value read before a local write is not kept
+	tc := c.(*tieredCache)
+	version := tc.local.version("k2")
+	assert.NoError(t, c.Set("k2", "new", 0))
+	tc.fillLocal("k2", "old", 0, version)
+	assert.NoError(t, c.Get("k2", &check))
+	assert.Equal(t, "new", check)
+
+	version = tc.local.version("k2")
+	assert.NoError(t, c.Del("k2"))
+	tc.fillLocal("k2", "old", 0, version)
+	_, found := tc.local.get("k2", clk.GetUnix())
+	assert.False(t, found)
+}
+
+func TestLRUCache(t *testing.T) {
+	l := newLRUCache(2)
+	l.add("k1", []byte("1"), 100)
+	l.add("k2", []byte("2"), 100)
+
+	_, found := l.get("k1", 0)
+	assert.True(t, found)
+
+	l.add("k3", []byte("3"), 100)
+	_, found = l.get("k2", 0)
+	assert.False(t, found)
+	assert.Equal(t, 2, l.len())
+
+	_, found = l.get("k1", 100)
+	assert.False(t, found)
+	assert.Equal(t, 1, l.len())
+}
@@ -0,0 +1,296 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/gor"
+	"github.com/chenjie4255/tools/log"
+)
+
+const typedShareInfoMaxRetry = 10
+
+// TypedShareInfo 在多个实例间共享一个可JSON序列化的值
+// Every Set and Del gets a new version number, a local copy is only replaced by a newer
+// version, so stale refreshes and delayed messages never overwrite newer data.
+type TypedShareInfo interface {
+	Set(val interface{}) error
+	// Get 将当前值解码到v中，值不存在或已删除时返回ResNotFound
+	Get(v interface{}) error
+	Del() error
+
+	// Version 本地副本的版本号，0表示从未设置过
+	Version() int64
+
+	// Watch 注册变化回调，回调按版本顺序依次调用，删除时delete为true，data为nil
+	// A callback may call Set, Del or Watch, the callbacks of such an update are called
+	// after the running callbacks return.
+	Watch(fn WatchCBFn)
+
+	// Close 停止刷新和订阅
+	Close() error
+}
+
+// shareEnvelope is the stored and published form of a TypedShareInfo value
+type shareEnvelope struct {
+	Version int64           `json:"v" msgpack:"v"`
+	Deleted bool            `json:"x,omitempty" msgpack:"x,omitempty"`
+	Data    json.RawMessage `json:"d,omitempty" msgpack:"d,omitempty"`
+}
+
+type typedShareInfo struct {
+	redisDB DB
+	sub     Subscriber
+
+	// keys
+	channelKey string
+	valueKey   string
+	versionKey string
+
+	rwlock  sync.RWMutex
+	current shareEnvelope
+
+	// applyMu keeps updates in version order, their callbacks are queued in pending and
+	// called without holding it by the caller which finds dispatching false
+	applyMu     sync.Mutex
+	callbacks   []WatchCBFn
+	pending     []shareEnvelope
+	dispatching bool
+
+	refreshInterval int
+	stop            chan struct{}
+	closeOnce       sync.Once
+}
+
+// NewTypedShareInfo 生成TypedShareInfo，forceRefresh为从redis强制刷新的间隔秒数，0表示不刷新
+// The TypedShareInfo is closed when ctx is done.
+func NewTypedShareInfo(ctx context.Context, infoName string, redisDB DB, forceRefresh int) TypedShareInfo {
+	ret := typedShareInfo{}
+	ret.redisDB = redisDB
+
+	ret.valueKey = fmt.Sprintf("redis_typed_share_info_%s", infoName)
+	ret.versionKey = fmt.Sprintf("redis_typed_share_info_version_%s", infoName)
+	ret.channelKey = fmt.Sprintf("redis_typed_share_info_channel_%s", infoName)
+	ret.refreshInterval = forceRefresh
+	ret.stop = make(chan struct{})
+
+	ret.watch()
+	ret.reload()
+	ret.loopRefresh(ctx)
+
+	return &ret
+}
+
+func (i *typedShareInfo) watch() {
+	i.sub = i.redisDB.NewSubscriber(i.onMessage, SubscriberOptions{
+		OnReconnect: i.reload,
+	})
+	if err := i.sub.Subscribe(i.channelKey); err != nil {
+		logger.AddFile().WithFields(log.Fields{
+			"channel": i.channelKey,
+			"error":   err,
+		}).Warn("failed to subscribe share info")
+	}
+}
+
+func (i *typedShareInfo) loopRefresh(ctx context.Context) {
+	gor.RunWithRecover(func() {
+		var tick <-chan time.Time
+		if i.refreshInterval > 0 {
+			ticker := time.NewTicker(time.Duration(i.refreshInterval) * time.Second)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				i.Close()
+				return
+			case <-i.stop:
+				return
+			case <-tick:
+				i.reload()
+			}
+		}
+	})
+}
+
+func (i *typedShareInfo) reload() {
+	env := shareEnvelope{}
+	if err := i.redisDB.Get(i.valueKey, &env); err != nil {
+		if !errors.FindTag(err, errcode.ResNotFound) {
+			logger.AddFile().WithFields(log.Fields{
+				"key":   i.valueKey,
+				"error": err,
+			}).Warn("failed to reload share info")
+		}
+		return
+	}
+
+	i.apply(env)
+}
+
+func (i *typedShareInfo) onMessage(channel string, data []byte) {
+	env := shareEnvelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return
+	}
+
+	i.apply(env)
+}
+
+// apply replaces the local copy if env is newer and calls the callbacks
+func (i *typedShareInfo) apply(env shareEnvelope) {
+	i.applyMu.Lock()
+
+	i.rwlock.Lock()
+	if env.Version <= i.current.Version {
+		i.rwlock.Unlock()
+		i.applyMu.Unlock()
+		return
+	}
+	i.current = env
+	i.rwlock.Unlock()
+
+	i.pending = append(i.pending, env)
+	if i.dispatching {
+		// called by a callback or racing with another dispatcher, which calls it in order
+		i.applyMu.Unlock()
+		return
+	}
+	i.dispatching = true
+	i.applyMu.Unlock()
+
+	i.dispatch()
+}
+
+// dispatch calls the callbacks of pending updates in order until none is left
+func (i *typedShareInfo) dispatch() {
+	// a panicking callback must not leave dispatching set, or no callback is called again
+	defer func() {
+		if err := recover(); err != nil {
+			i.applyMu.Lock()
+			i.dispatching = false
+			i.applyMu.Unlock()
+			panic(err)
+		}
+	}()
+
+	for {
+		i.applyMu.Lock()
+		if len(i.pending) == 0 {
+			i.dispatching = false
+			i.applyMu.Unlock()
+			return
+		}
+		env := i.pending[0]
+		i.pending = i.pending[1:]
+		callbacks := append([]WatchCBFn(nil), i.callbacks...)
+		i.applyMu.Unlock()
+
+		for _, fn := range callbacks {
+			fn(env.Deleted, env.Data)
+		}
+	}
+}
+
+// write stores env unless a newer version is already stored
+func (i *typedShareInfo) write(env shareEnvelope) error {
+	for retry := 0; retry < typedShareInfoMaxRetry; retry++ {
+		stored := shareEnvelope{}
+		err := i.redisDB.Get(i.valueKey, &stored)
+		if errors.FindTag(err, errcode.ResNotFound) {
+			err = i.redisDB.SetNotExists(i.valueKey, env, 0)
+			if errors.FindTag(err, errcode.ResExisted) {
+				continue
+			}
+		} else if err != nil {
+			return err
+		} else if stored.Version > env.Version {
+			// superseded by a newer version
+			i.apply(stored)
+			return nil
+		} else if err = i.redisDB.ReplaceValue(i.valueKey, stored, env); err != nil {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		i.apply(env)
+		data, _ := json.Marshal(env)
+		_, err = i.redisDB.Publish(i.channelKey, data)
+		return err
+	}
+
+	return errors.New("share info is updated too frequently")
+}
+
+func (i *typedShareInfo) nextVersion() (int64, error) {
+	version, err := i.redisDB.IncrByUint64(i.versionKey, 1)
+	return int64(version), err
+}
+
+func (i *typedShareInfo) Set(val interface{}) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	version, err := i.nextVersion()
+	if err != nil {
+		return err
+	}
+
+	return i.write(shareEnvelope{Version: version, Data: data})
+}
+
+func (i *typedShareInfo) Get(v interface{}) error {
+	i.rwlock.RLock()
+	env := i.current
+	i.rwlock.RUnlock()
+
+	if env.Version == 0 || env.Deleted {
+		return errors.NewWithTag("share info doesn't exists", errcode.ResNotFound)
+	}
+
+	return json.Unmarshal(env.Data, v)
+}
+
+func (i *typedShareInfo) Del() error {
+	version, err := i.nextVersion()
+	if err != nil {
+		return err
+	}
+
+	return i.write(shareEnvelope{Version: version, Deleted: true})
+}
+
+func (i *typedShareInfo) Version() int64 {
+	i.rwlock.RLock()
+	defer i.rwlock.RUnlock()
+
+	return i.current.Version
+}
+
+func (i *typedShareInfo) Watch(fn WatchCBFn) {
+	i.applyMu.Lock()
+	defer i.applyMu.Unlock()
+
+	i.callbacks = append(i.callbacks, fn)
+}
+
+func (i *typedShareInfo) Close() error {
+	i.closeOnce.Do(func() {
+		close(i.stop)
+		i.sub.Close()
+	})
+	return nil
+}
@@ -0,0 +1,129 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedShareInfo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	runTypedShareInfoSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func TestMemoryTypedShareInfo(t *testing.T) {
+	runTypedShareInfoSuite(t, NewMemoryDB(nil))
+}
+
+func TestTypedShareInfoWatchReentrant(t *testing.T) {
+	info := NewTypedShareInfo(context.Background(), "reentrant", NewMemoryDB(nil), 0)
+	defer info.Close()
+
+	events := make(chan string, 2)
+	info.Watch(func(delete bool, data []byte) {
+		events <- string(data)
+		// updating from a callback does not deadlock
+		if string(data) == `"first"` {
+			assert.NoError(t, info.Set("second"))
+		}
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- info.Set("first")
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Set from a callback deadlocks")
+	}
+	assert.Equal(t, `"first"`, <-events)
+	assert.Equal(t, `"second"`, <-events)
+}
+
+func runTypedShareInfoSuite(t *testing.T, db DB) {
+	type Config struct {
+		Name    string `json:"name"`
+		Enabled bool   `json:"enabled"`
+	}
+
+	info1 := NewTypedShareInfo(context.Background(), "typed", db, 0)
+	info2 := NewTypedShareInfo(context.Background(), "typed", db, 0)
+	defer info1.Close()
+	defer info2.Close()
+
+	check := Config{}
+	assert.True(t, errors.FindTag(info1.Get(&check), errcode.ResNotFound))
+	assert.Equal(t, int64(0), info1.Version())
+
+	var mu sync.Mutex
+	events := []string{}
+	info2.Watch(func(delete bool, data []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		if delete {
+			events = append(events, "deleted")
+		} else {
+			events = append(events, string(data))
+		}
+	})
+
+	assert.NoError(t, info1.Set(Config{"a", true}))
+	assert.NoError(t, info1.Get(&check))
+	assert.Equal(t, Config{"a", true}, check)
+	assert.Eventually(t, func() bool {
+		return info2.Version() == info1.Version()
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.NoError(t, info2.Get(&check))
+	assert.Equal(t, Config{"a", true}, check)
+
+	// stale data never overwrites newer one
+	version := info2.Version()
+	typed2 := info2.(*typedShareInfo)
+	typed2.onMessage(typed2.channelKey, []byte(`{"v":0,"d":{"name":"stale"}}`))
+	assert.NoError(t, db.Set(typed2.valueKey, shareEnvelope{Version: version - 1, Data: json.RawMessage(`{"name":"stale"}`)}, 0))
+	typed2.reload()
+	assert.NoError(t, info2.Get(&check))
+	assert.Equal(t, Config{"a", true}, check)
+
+	// a writer with an older version gives up
+	assert.NoError(t, typed2.write(shareEnvelope{Version: version - 1, Data: json.RawMessage(`{"name":"stale"}`)}))
+	assert.NoError(t, info2.Set(Config{"b", false}))
+	assert.NoError(t, typed2.write(shareEnvelope{Version: version, Data: json.RawMessage(`{"name":"stale"}`)}))
+	assert.NoError(t, info2.Get(&check))
+	assert.Equal(t, Config{"b", false}, check)
+
+	assert.NoError(t, info1.Del())
+	assert.True(t, errors.FindTag(info1.Get(&check), errcode.ResNotFound))
+	assert.Eventually(t, func() bool {
+		return errors.FindTag(info2.Get(&check), errcode.ResNotFound)
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// a new instance loads the latest version
+	info3 := NewTypedShareInfo(context.Background(), "typed", db, 1)
+	defer info3.Close()
+	assert.Equal(t, info1.Version(), info3.Version())
+
+	mu.Lock()
+	assert.Equal(t, []string{`{"name":"a","enabled":true}`, `{"name":"b","enabled":false}`, "deleted"}, events)
+	mu.Unlock()
+
+	assert.NoError(t, info3.Close())
+	assert.NoError(t, info3.Close())
+}
@@ -0,0 +1,106 @@
+package redis
+
+import (
+	"context"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/gomodule/redigo/redis"
+)
+
+// ZMember 有序集合中的成员及其分数
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+func zMemberNotFoundError() error {
+	return errors.NewWithTag("member doesn't exists", errcode.ResNotFound)
+}
+
+// zMembers converts a WITHSCORES reply into members
+func zMembers(reply interface{}, err error) ([]ZMember, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redigo: ZMembers expects even number of values result")
+	}
+
+	ret := make([]ZMember, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		member, err := redis.String(values[i], nil)
+		if err != nil {
+			return nil, err
+		}
+		score, err := redis.Float64(values[i+1], nil)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, ZMember{member, score})
+	}
+	return ret, nil
+}
+
+func (d *db) ZAddCtx(ctx context.Context, key string, members ...ZMember) (int, error) {
+	args := []interface{}{key}
+	for i := range members {
+		args = append(args, members[i].Score, members[i].Member)
+	}
+
+	return redis.Int(d.do(ctx, "ZADD", args...))
+}
+
+func (d *db) ZIncrByCtx(ctx context.Context, key, member string, step float64) (float64, error) {
+	return redis.Float64(d.do(ctx, "ZINCRBY", key, step, member))
+}
+
+func (d *db) ZScoreCtx(ctx context.Context, key, member string) (float64, error) {
+	score, err := redis.Float64(d.do(ctx, "ZSCORE", key, member))
+	if err == redis.ErrNil {
+		return 0, zMemberNotFoundError()
+	}
+	return score, err
+}
+
+func (d *db) zrank(ctx context.Context, cmd, key, member string) (int, error) {
+	rank, err := redis.Int(d.do(ctx, cmd, key, member))
+	if err == redis.ErrNil {
+		return 0, zMemberNotFoundError()
+	}
+	return rank, err
+}
+
+func (d *db) ZRankCtx(ctx context.Context, key, member string) (int, error) {
+	return d.zrank(ctx, "ZRANK", key, member)
+}
+
+func (d *db) ZRevRankCtx(ctx context.Context, key, member string) (int, error) {
+	return d.zrank(ctx, "ZREVRANK", key, member)
+}
+
+func (d *db) ZRangeWithScoresCtx(ctx context.Context, key string, start, stop int) ([]ZMember, error) {
+	return zMembers(d.do(ctx, "ZRANGE", key, start, stop, "WITHSCORES"))
+}
+
+func (d *db) ZRevRangeWithScoresCtx(ctx context.Context, key string, start, stop int) ([]ZMember, error) {
+	return zMembers(d.do(ctx, "ZREVRANGE", key, start, stop, "WITHSCORES"))
+}
+
+func (d *db) ZRangeByScoreWithScoresCtx(ctx context.Context, key string, min, max float64, offset, count int) ([]ZMember, error) {
+	return zMembers(d.do(ctx, "ZRANGEBYSCORE", key, min, max, "WITHSCORES", "LIMIT", offset, count))
+}
+
+func (d *db) ZRevRangeByScoreWithScoresCtx(ctx context.Context, key string, max, min float64, offset, count int) ([]ZMember, error) {
+	return zMembers(d.do(ctx, "ZREVRANGEBYSCORE", key, max, min, "WITHSCORES", "LIMIT", offset, count))
+}
+
+func (d *db) ZRemCtx(ctx context.Context, key string, members ...string) (int, error) {
+	args := []interface{}{key}
+	for i := range members {
+		args = append(args, members[i])
+	}
+
+	return redis.Int(d.do(ctx, "ZREM", args...))
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/chenjie4255/errors"
+	"github.com/chenjie4255/tools/errcode"
+	"github.com/chenjie4255/tools/testenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZSetOp(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	FlushDB(env.RedisHost, "", 3)
+	runZSetSuite(t, NewDB(env.RedisHost, "", 3))
+}
+
+func TestMemoryZSetOp(t *testing.T) {
+	runZSetSuite(t, NewMemoryDB(nil))
+}
+
+func runZSetSuite(t *testing.T, db DB) {
+	count, err := db.ZAdd("board", ZMember{"a", 10}, ZMember{"b", 20}, ZMember{"c", 30})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	count, err = db.ZAdd("board", ZMember{"c", 5}, ZMember{"d", 40})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	score, err := db.ZIncrBy("board", "a", 2.5)
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, score)
+	score, err = db.ZIncrBy("board", "e", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, score)
+
+	score, err = db.ZScore("board", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, score)
+	_, err = db.ZScore("board", "x")
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+	_, err = db.ZScore("nx", "x")
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+
+	// e:1 c:5 a:12.5 b:20 d:40
+	rank, err := db.ZRank("board", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rank)
+	rank, err = db.ZRevRank("board", "d")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rank)
+	_, err = db.ZRevRank("board", "x")
+	assert.True(t, errors.FindTag(err, errcode.ResNotFound))
+
+	members, err := db.ZRevRangeWithScores("board", 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"d", 40}, {"b", 20}, {"a", 12.5}}, members)
+	members, err = db.ZRangeWithScores("board", -2, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"b", 20}, {"d", 40}}, members)
+	members, err = db.ZRangeWithScores("board", 10, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{}, members)
+	members, err = db.ZRangeWithScores("nx", 0, -1)
+	assert.NoError(t, err)
+	assert.Len(t, members, 0)
+
+	members, err = db.ZRangeByScoreWithScores("board", 5, 20, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 12.5}, {"b", 20}}, members)
+	members, err = db.ZRangeByScoreWithScores("board", math.Inf(-1), math.Inf(1), 3, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"b", 20}, {"d", 40}}, members)
+	members, err = db.ZRevRangeByScoreWithScores("board", 20, 0, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []ZMember{{"b", 20}, {"a", 12.5}}, members)
+
+	count, err = db.ZRem("board", "a", "x")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = db.ZRem("board", "b", "c", "d", "e")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+	exists, err := db.Exists("board")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.NoError(t, db.Set("s1", 1, 0))
+	_, err = db.ZAdd("s1", ZMember{"a", 1})
+	assert.Error(t, err)
+}
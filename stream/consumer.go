@@ -0,0 +1,226 @@
+package stream
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/chenjie4255/errors"
+
+	"github.com/chenjie4255/tools/gor"
+	"github.com/chenjie4255/tools/log"
+)
+
+const (
+	defaultConsumerCount         = 10
+	defaultConsumerBlock         = 5 * time.Second
+	defaultConsumerClaimMinIdle  = time.Minute
+	defaultConsumerClaimInterval = 30 * time.Second
+	consumerRetryInterval        = time.Second
+)
+
+// Handler 处理一条消息，返回nil时消息被确认
+// A message is left pending if the handler returns an error or panics, and it will be
+// claimed again once it has been idle for ClaimMinIdle.
+type Handler func(ctx context.Context, msg Message) error
+
+// ConsumerOptions 配置Consumer的读取和认领策略
+type ConsumerOptions struct {
+	// Count 每次读取的最大消息数，默认10
+	Count int
+
+	// Block 每次读取的最长阻塞时间，默认5秒
+	Block time.Duration
+
+	// ClaimMinIdle 未确认消息空闲超过该时长后被认领，默认1分钟，小于0表示不认领
+	// It should be longer than the slowest handler, or a message in progress may be
+	// handled twice.
+	ClaimMinIdle time.Duration
+
+	// ClaimInterval 检查空闲消息的间隔，默认30秒
+	ClaimInterval time.Duration
+
+	// StartID 消费组不存在时创建消费组的起始ID，默认"$"
+	StartID string
+}
+
+// Consumer 消费组中的一个消费者
+type Consumer struct {
+	client    Client
+	stream    string
+	group     string
+	name      string
+	handler   Handler
+	opts      ConsumerOptions
+	cancel    context.CancelFunc
+	done      chan struct{}
+	claimAt   time.Time
+	claimNext string
+}
+
+// NewConsumer 生成消费者，同一消费组内的消费者name应唯一
+func NewConsumer(client Client, stream, group, name string, handler Handler, opts ConsumerOptions) *Consumer {
+	if opts.Count <= 0 {
+		opts.Count = defaultConsumerCount
+	}
+	if opts.Block <= 0 {
+		opts.Block = defaultConsumerBlock
+	}
+	if opts.ClaimMinIdle == 0 {
+		opts.ClaimMinIdle = defaultConsumerClaimMinIdle
+	}
+	if opts.ClaimInterval <= 0 {
+		opts.ClaimInterval = defaultConsumerClaimInterval
+	}
+
+	return &Consumer{
+		client:  client,
+		stream:  stream,
+		group:   group,
+		name:    name,
+		handler: handler,
+		opts:    opts,
+	}
+}
+
+// Start 在新的协程中运行Run，调用Stop结束
+func (c *Consumer) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	c.done = make(chan struct{})
+
+	gor.RunWithRecover(func() {
+		defer close(c.done)
+		c.Run(ctx)
+	})
+}
+
+// Stop 结束Start启动的消费循环，并等待正在处理的消息完成
+func (c *Consumer) Stop() {
+	if c.cancel == nil {
+		return
+	}
+
+	c.cancel()
+	<-c.done
+}
+
+// Run 循环读取并处理消息，直到ctx结束
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		if err := c.client.CreateGroup(ctx, c.stream, c.group, c.opts.StartID); err == nil {
+			break
+		} else if !c.retry(ctx, err, "failed to create consumer group") {
+			return ctx.Err()
+		}
+	}
+
+	for ctx.Err() == nil {
+		if err := c.claim(ctx); err != nil {
+			if !c.retry(ctx, err, "failed to claim pending messages") {
+				break
+			}
+			continue
+		}
+
+		msgs, err := c.client.ReadGroup(ctx, c.stream, c.group, c.name, c.opts.Count, c.opts.Block)
+		if err != nil {
+			if !c.retry(ctx, err, "failed to read messages") {
+				break
+			}
+			continue
+		}
+
+		c.process(ctx, msgs)
+	}
+
+	return ctx.Err()
+}
+
+// claim takes over messages of dead consumers, a full scan is split into batches of
+// Count across loops
+func (c *Consumer) claim(ctx context.Context) error {
+	if c.opts.ClaimMinIdle < 0 {
+		return nil
+	}
+	if c.claimNext == "" && time.Now().Before(c.claimAt) {
+		return nil
+	}
+
+	next, msgs, err := c.client.AutoClaim(ctx, c.stream, c.group, c.name, c.opts.ClaimMinIdle, c.claimNext, c.opts.Count)
+	if err != nil {
+		return err
+	}
+
+	if next == "0-0" {
+		c.claimNext = ""
+		c.claimAt = time.Now().Add(c.opts.ClaimInterval)
+	} else {
+		c.claimNext = next
+	}
+
+	c.process(ctx, msgs)
+	return nil
+}
+
+func (c *Consumer) process(ctx context.Context, msgs []Message) {
+	for _, msg := range msgs {
+		// deleted messages can never be handled, just drop them from the pending list
+		if msg.Values != nil {
+			if err := c.handle(ctx, msg); err != nil {
+				logger.AddFile().WithFields(log.Fields{
+					"stream": c.stream,
+					"group":  c.group,
+					"id":     msg.ID,
+					"error":  err,
+				}).Warn("failed to handle stream message")
+				continue
+			}
+		}
+
+		if _, err := c.client.Ack(context.Background(), c.stream, c.group, msg.ID); err != nil {
+			logger.AddFile().WithFields(log.Fields{
+				"stream": c.stream,
+				"group":  c.group,
+				"id":     msg.ID,
+				"error":  err,
+			}).Warn("failed to ack stream message")
+		}
+	}
+}
+
+// handle calls the handler with the same recover protection as gor.RunWithRecover
+func (c *Consumer) handle(ctx context.Context, msg Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.WithFields(log.Fields{
+				"panic":  r,
+				"stack":  string(debug.Stack()),
+				"stream": c.stream,
+				"id":     msg.ID,
+			}).Error("recover panic from stream handler!")
+			err = errors.Errorf("stream handler panic: %v", r)
+		}
+	}()
+
+	return c.handler(ctx, msg)
+}
+
+// retry logs err and waits before the next attempt, it returns false if ctx is done
+func (c *Consumer) retry(ctx context.Context, err error, msg string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	logger.AddFile().WithFields(log.Fields{
+		"stream": c.stream,
+		"group":  c.group,
+		"error":  err,
+	}).Warn(msg)
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(consumerRetryInterval):
+		return true
+	}
+}
@@ -0,0 +1,243 @@
+package stream
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/gomodule/redigo/redis"
+
+	"github.com/chenjie4255/tools/log"
+	rediscm "github.com/chenjie4255/tools/redis"
+)
+
+var logger *log.Logger
+
+func init() {
+	logger = log.NewLoggerWithSentry("stream")
+}
+
+// Message 流中的一条消息
+// Values is nil if the message has been deleted from the stream but is still pending.
+type Message struct {
+	ID     string
+	Values map[string]string
+}
+
+// PendingEntry 已投递但未确认的消息
+type PendingEntry struct {
+	ID            string
+	Consumer      string
+	Idle          time.Duration
+	DeliveryCount int64
+}
+
+// Client 基于redis streams的消息队列，消息在被确认前不会丢失
+type Client interface {
+	// Add 向stream追加消息，maxLen大于0时近似裁剪到maxLen条(MAXLEN ~)
+	Add(ctx context.Context, stream string, values map[string]interface{}, maxLen int64) (string, error)
+	Len(ctx context.Context, stream string) (int64, error)
+
+	// CreateGroup 创建消费组，stream不存在时自动创建，消费组已存在时不返回错误
+	// startID "$" means only new messages are delivered, "0" means the whole stream.
+	CreateGroup(ctx context.Context, stream, group, startID string) error
+	// ReadGroup 读取未投递过的消息，block大于0时最多阻塞block，没有消息时返回空
+	ReadGroup(ctx context.Context, stream, group, consumer string, count int, block time.Duration) ([]Message, error)
+	Ack(ctx context.Context, stream, group string, ids ...string) (int, error)
+
+	// Pending 返回消费组中最早的count条未确认消息
+	Pending(ctx context.Context, stream, group string, count int) ([]PendingEntry, error)
+	// AutoClaim 将空闲超过minIdle的未确认消息转给consumer，返回下一次扫描的起始ID，扫描完毕时为"0-0"
+	AutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int) (string, []Message, error)
+}
+
+func New(host, password string, dbNum int, opts ...rediscm.PoolOption) Client {
+	pool := rediscm.NewPool(host, password, dbNum, opts...)
+	return &client{pool}
+}
+
+func NewWithPool(pool *redis.Pool) Client {
+	return &client{pool}
+}
+
+type client struct {
+	pool *redis.Pool
+}
+
+func (c *client) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+func (c *client) Add(ctx context.Context, stream string, values map[string]interface{}, maxLen int64) (string, error) {
+	if len(values) == 0 {
+		return "", errors.New("stream message should have at least one field")
+	}
+
+	args := []interface{}{stream}
+	if maxLen > 0 {
+		args = append(args, "MAXLEN", "~", maxLen)
+	}
+	args = append(args, "*")
+	for field, value := range values {
+		args = append(args, field, value)
+	}
+
+	return redis.String(c.do(ctx, "XADD", args...))
+}
+
+func (c *client) Len(ctx context.Context, stream string) (int64, error) {
+	return redis.Int64(c.do(ctx, "XLEN", stream))
+}
+
+func (c *client) CreateGroup(ctx context.Context, stream, group, startID string) error {
+	if startID == "" {
+		startID = "$"
+	}
+
+	_, err := c.do(ctx, "XGROUP", "CREATE", stream, group, startID, "MKSTREAM")
+	if rerr, ok := err.(redis.Error); ok && strings.HasPrefix(string(rerr), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+func (c *client) ReadGroup(ctx context.Context, stream, group, consumer string, count int, block time.Duration) ([]Message, error) {
+	args := []interface{}{"GROUP", group, consumer}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	if block > 0 {
+		args = append(args, "BLOCK", int64(block/time.Millisecond))
+	}
+	args = append(args, "STREAMS", stream, ">")
+
+	streams, err := redis.Values(c.do(ctx, "XREADGROUP", args...))
+	if err != nil {
+		if err == redis.ErrNil {
+			return []Message{}, nil
+		}
+		return nil, err
+	}
+
+	ret := []Message{}
+	for i := range streams {
+		values, err := redis.Values(streams[i], nil)
+		if err != nil || len(values) != 2 {
+			return nil, errors.Errorf("unexpected XREADGROUP reply %v", streams[i])
+		}
+
+		msgs, err := parseMessages(values[1])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, msgs...)
+	}
+	return ret, nil
+}
+
+func (c *client) Ack(ctx context.Context, stream, group string, ids ...string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	args := []interface{}{stream, group}
+	for i := range ids {
+		args = append(args, ids[i])
+	}
+
+	return redis.Int(c.do(ctx, "XACK", args...))
+}
+
+func (c *client) Pending(ctx context.Context, stream, group string, count int) ([]PendingEntry, error) {
+	values, err := redis.Values(c.do(ctx, "XPENDING", stream, group, "-", "+", count))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]PendingEntry, 0, len(values))
+	for i := range values {
+		fields, err := redis.Values(values[i], nil)
+		if err != nil || len(fields) != 4 {
+			return nil, errors.Errorf("unexpected XPENDING reply %v", values[i])
+		}
+
+		entry := PendingEntry{}
+		entry.ID, _ = redis.String(fields[0], nil)
+		entry.Consumer, _ = redis.String(fields[1], nil)
+		idle, _ := redis.Int64(fields[2], nil)
+		entry.Idle = time.Duration(idle) * time.Millisecond
+		entry.DeliveryCount, _ = redis.Int64(fields[3], nil)
+		ret = append(ret, entry)
+	}
+	return ret, nil
+}
+
+func (c *client) AutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int) (string, []Message, error) {
+	if start == "" {
+		start = "0-0"
+	}
+
+	args := []interface{}{stream, group, consumer, int64(minIdle / time.Millisecond), start}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+
+	values, err := redis.Values(c.do(ctx, "XAUTOCLAIM", args...))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(values) < 2 {
+		return "", nil, errors.Errorf("unexpected XAUTOCLAIM reply %v", values)
+	}
+
+	next, err := redis.String(values[0], nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	msgs, err := parseMessages(values[1])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return next, msgs, nil
+}
+
+// parseMessages parses a list of [id, [field, value, ...]] entries
+func parseMessages(reply interface{}) ([]Message, error) {
+	entries, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Message, 0, len(entries))
+	for i := range entries {
+		if entries[i] == nil {
+			continue
+		}
+
+		values, err := redis.Values(entries[i], nil)
+		if err != nil || len(values) != 2 {
+			return nil, errors.Errorf("unexpected stream entry %v", entries[i])
+		}
+
+		msg := Message{}
+		if msg.ID, err = redis.String(values[0], nil); err != nil {
+			return nil, err
+		}
+		if values[1] != nil {
+			if msg.Values, err = redis.StringMap(values[1], nil); err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, msg)
+	}
+	return ret, nil
+}
@@ -0,0 +1,152 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chenjie4255/errors"
+	"github.com/stretchr/testify/assert"
+
+	rediscm "github.com/chenjie4255/tools/redis"
+	"github.com/chenjie4255/tools/testenv"
+)
+
+func TestParseMessages(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{[]byte("1-0"), []interface{}{[]byte("k"), []byte("v")}},
+		[]interface{}{[]byte("2-0"), nil},
+		nil,
+	}
+
+	msgs, err := parseMessages(reply)
+	assert.NoError(t, err)
+	assert.Equal(t, []Message{{"1-0", map[string]string{"k": "v"}}, {"2-0", nil}}, msgs)
+
+	_, err = parseMessages([]interface{}{[]interface{}{[]byte("1-0")}})
+	assert.Error(t, err)
+}
+
+func newTestClient(t *testing.T) Client {
+	if testing.Short() {
+		t.Skip("skip integrated test in short mode")
+	}
+	env := testenv.GetIntegratedTestEnv()
+	if env.RedisHost == "" {
+		t.Skip("env not configured yet, skip this test")
+	}
+
+	rediscm.FlushDB(env.RedisHost, "", 3)
+	return New(env.RedisHost, "", 3)
+}
+
+func TestStream(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	assert.NoError(t, c.CreateGroup(ctx, "s1", "g1", "0"))
+	assert.NoError(t, c.CreateGroup(ctx, "s1", "g1", "0"))
+
+	for i := 0; i < 3; i++ {
+		_, err := c.Add(ctx, "s1", map[string]interface{}{"n": i}, 0)
+		assert.NoError(t, err)
+	}
+	_, err := c.Add(ctx, "s1", nil, 0)
+	assert.Error(t, err)
+
+	count, err := c.Len(ctx, "s1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	msgs, err := c.ReadGroup(ctx, "s1", "g1", "c1", 2, 0)
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, "0", msgs[0].Values["n"])
+
+	pending, err := c.Pending(ctx, "s1", "g1", 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 2)
+	assert.Equal(t, "c1", pending[0].Consumer)
+	assert.Equal(t, int64(1), pending[0].DeliveryCount)
+
+	acked, err := c.Ack(ctx, "s1", "g1", msgs[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, acked)
+
+	// c1 is dead, c2 takes over its message
+	next, claimed, err := c.AutoClaim(ctx, "s1", "g1", "c2", 0, "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "0-0", next)
+	assert.Len(t, claimed, 1)
+	assert.Equal(t, msgs[1].ID, claimed[0].ID)
+
+	pending, err = c.Pending(ctx, "s1", "g1", 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "c2", pending[0].Consumer)
+
+	msgs, err = c.ReadGroup(ctx, "s1", "g1", "c1", 10, 100*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 1)
+	msgs, err = c.ReadGroup(ctx, "s1", "g1", "c1", 10, 100*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 0)
+
+	for i := 0; i < 200; i++ {
+		_, err := c.Add(ctx, "s2", map[string]interface{}{"n": i}, 10)
+		assert.NoError(t, err)
+	}
+	count, err = c.Len(ctx, "s2")
+	assert.NoError(t, err)
+	assert.True(t, count < 200)
+}
+
+func TestConsumer(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	handler := func(ctx context.Context, msg Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled[msg.Values["n"]]++
+		switch msg.Values["n"] {
+		case "panic":
+			if handled["panic"] == 1 {
+				panic("boom")
+			}
+		case "fail":
+			if handled["fail"] == 1 {
+				return errors.New("fail")
+			}
+		}
+		return nil
+	}
+
+	consumer := NewConsumer(c, "s1", "g1", "c1", handler, ConsumerOptions{
+		Block:         100 * time.Millisecond,
+		ClaimMinIdle:  time.Millisecond,
+		ClaimInterval: 100 * time.Millisecond,
+		StartID:       "0",
+	})
+	for _, n := range []string{"ok", "panic", "fail"} {
+		_, err := c.Add(ctx, "s1", map[string]interface{}{"n": n}, 0)
+		assert.NoError(t, err)
+	}
+
+	consumer.Start(ctx)
+	defer consumer.Stop()
+
+	// failed messages are claimed and handled again
+	assert.Eventually(t, func() bool {
+		pending, err := c.Pending(ctx, "s1", "g1", 10)
+		return err == nil && len(pending) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, map[string]int{"ok": 1, "panic": 2, "fail": 2}, handled)
+	mu.Unlock()
+}